@@ -0,0 +1,39 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"strings"
+
+	"github.com/aymanbagabas/go-osc52/v2"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mattn/go-isatty"
+)
+
+type copiedMsg struct {
+	lines int
+	err   error
+}
+
+// copyToClipboard puts text on the clipboard with an OSC 52 escape sequence,
+// which the terminal handles even when jenkins-log-streamer runs over SSH.
+// Inside tmux or screen the sequence is passed through to the terminal.
+//
+// The sequence goes to out, the terminal the program renders to, in a single
+// write. Writes to a file don't interleave, and the renderer writes each
+// frame in one, so the sequence can't land in the middle of a frame.
+func copyToClipboard(out *os.File, text string, lines int) tea.Cmd {
+	return func() tea.Msg {
+		if !isatty.IsTerminal(out.Fd()) && !isatty.IsCygwinTerminal(out.Fd()) {
+			return copiedMsg{err: errors.New("the output is not a terminal")}
+		}
+		seq := osc52.New(text)
+		if os.Getenv("TMUX") != "" {
+			seq = seq.Tmux()
+		} else if strings.HasPrefix(os.Getenv("TERM"), "screen") {
+			seq = seq.Screen()
+		}
+		_, err := out.WriteString(seq.String())
+		return copiedMsg{lines: lines, err: err}
+	}
+}
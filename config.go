@@ -0,0 +1,193 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// config holds the settings read from the config file.
+type config struct {
+	// Keys replaces the keys of actions, by name. An empty list disables
+	// the action.
+	Keys map[string][]string `toml:"keys"`
+	// Servers are the Jenkins servers jobs run on, by name
+	Servers map[string]serverConfig `toml:"servers"`
+	// Jobs are aliases for jobs, given as the argument instead of --url
+	Jobs map[string]jobConfig `toml:"jobs"`
+}
+
+// serverConfig is a Jenkins server and how to log in to it.
+type serverConfig struct {
+	URL  string `toml:"url"`
+	User string `toml:"user"`
+	// The API token is one of: Token itself, in the environment variable
+	// TokenEnv, in the file TokenFile, or the output of TokenCommand
+	Token        string `toml:"token"`
+	TokenEnv     string `toml:"token_env"`
+	TokenFile    string `toml:"token_file"`
+	TokenCommand string `toml:"token_command"`
+	// Auth is how the token is sent: "basic" with the user, the default,
+	// "bearer", or "cookie" for session cookies
+	Auth string `toml:"auth"`
+	// Headers are sent with every request to the server
+	Headers map[string]string `toml:"headers"`
+	// Proxy is the URL of the proxy to connect to the server through, or
+	// "none" to connect directly
+	Proxy string `toml:"proxy"`
+	// CAFile is a PEM bundle of certificate authorities the server's
+	// certificate is signed by, and CertFile and KeyFile are a client
+	// certificate for servers that require one
+	CAFile   string `toml:"ca_file"`
+	CertFile string `toml:"cert_file"`
+	KeyFile  string `toml:"key_file"`
+	// InsecureSkipVerify accepts any certificate from the server
+	InsecureSkipVerify bool `toml:"insecure_skip_verify"`
+	// name is the server's name in the config file
+	name string
+}
+
+// jobConfig is a job on one of the servers.
+type jobConfig struct {
+	Server string `toml:"server"`
+	// Job is the path to the job through its folders, like
+	// "Projects/demo/main", or the URL of the job
+	Job string `toml:"job"`
+}
+
+// defaultConfigPath returns where the config file is read from unless
+// --config says otherwise, in the user's config directory.
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "jenkins-log-streamer", "config.toml")
+}
+
+// loadConfig reads the config file at path. A missing file is only an error
+// if it was asked for explicitly.
+func loadConfig(path string, explicit bool) (config, error) {
+	var c config
+	if path == "" {
+		return c, nil
+	}
+	md, err := toml.DecodeFile(path, &c)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) && !explicit {
+			return config{}, nil
+		}
+		return c, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return c, fmt.Errorf("%s: unknown setting %s", path, undecoded[0])
+	}
+	return c, nil
+}
+
+// keyBindings names the bindings of the program and the viewport for the
+// config file.
+func keyBindings(app *keyMap, log *jlsviewport.KeyMap) map[string]*key.Binding {
+	return map[string]*key.Binding{
+		"quit":            &app.Quit,
+		"force_quit":      &app.ForceQuit,
+		"back":            &app.Back,
+		"help":            &app.Help,
+		"search":          &app.Search,
+		"search_backward": &app.SearchBackward,
+		"filter":          &app.Filter,
+		"command":         &app.Command,
+		"save":            &app.Save,
+		"timestamps":      &app.Timestamps,
+		"history":         &app.History,
+		"toggle_stages":   &app.ToggleStages,
+		"focus_stages":    &app.FocusStages,
+		"show_stage":      &app.ShowStage,
+		"open_build":      &app.OpenBuild,
+		"follow_latest":   &app.FollowLatest,
+		"prompt_submit":   &app.PromptSubmit,
+		"prompt_cancel":   &app.PromptCancel,
+		"page_down":       &log.PageDown,
+		"page_up":         &log.PageUp,
+		"half_page_up":    &log.HalfPageUp,
+		"half_page_down":  &log.HalfPageDown,
+		"down":            &log.Down,
+		"up":              &log.Up,
+		"goto_top":        &log.GotoTop,
+		"goto_bottom":     &log.GotoBottom,
+		"toggle_fold":     &log.ToggleFold,
+		"collapse_all":    &log.CollapseAll,
+		"expand_all":      &log.ExpandAll,
+		"toggle_wrap":     &log.ToggleWrap,
+		"toggle_color":    &log.ToggleColor,
+		"next_match":      &log.NextMatch,
+		"prev_match":      &log.PrevMatch,
+		"more_context":    &log.MoreContext,
+		"less_context":    &log.LessContext,
+		"next_mark":       &log.NextMark,
+		"prev_mark":       &log.PrevMark,
+		"select":          &log.Select,
+		"select_screen":   &log.SelectScreen,
+		"copy":            &log.Copy,
+	}
+}
+
+// keyContexts lists the actions that take keys on each screen. A key can do
+// different things on different screens, but only one thing on each.
+var keyContexts = []struct {
+	name    string
+	actions []string
+}{
+	{"log", []string{"quit", "force_quit", "back", "help", "search", "search_backward", "filter", "command", "save",
+		"timestamps", "history", "toggle_stages", "focus_stages", "page_down", "page_up", "half_page_up",
+		"half_page_down", "down", "up", "goto_top", "goto_bottom", "toggle_fold", "collapse_all", "expand_all",
+		"toggle_wrap", "toggle_color", "next_match", "prev_match", "more_context", "less_context", "next_mark",
+		"prev_mark", "select", "select_screen", "copy"}},
+	{"stage panel", []string{"quit", "force_quit", "back", "focus_stages", "up", "down", "show_stage"}},
+	{"build history", []string{"quit", "force_quit", "back", "history", "open_build", "follow_latest", "page_down",
+		"page_up", "half_page_up", "half_page_down", "down", "up", "goto_top", "goto_bottom"}},
+	{"prompt", []string{"force_quit", "prompt_submit", "prompt_cancel"}},
+}
+
+// applyKeys rebinds the actions named in the config file, and checks that
+// no key is left bound to two actions on the same screen. The help shows the
+// new keys.
+func applyKeys(keys map[string][]string, app *keyMap, log *jlsviewport.KeyMap) error {
+	bindings := keyBindings(app, log)
+	for name, k := range keys {
+		b, ok := bindings[name]
+		if !ok {
+			return fmt.Errorf("unknown action %q in [keys]", name)
+		}
+		if len(k) == 0 {
+			b.SetEnabled(false)
+			continue
+		}
+		b.SetKeys(k...)
+		b.SetHelp(strings.Join(k, "/"), b.Help().Desc)
+	}
+
+	for _, c := range keyContexts {
+		actions := make(map[string]string)
+		for _, name := range c.actions {
+			b := bindings[name]
+			if !b.Enabled() {
+				continue
+			}
+			for _, k := range b.Keys() {
+				if other, ok := actions[k]; ok {
+					return fmt.Errorf("key %q is bound to both %q and %q in the %s", k, other, name, c.name)
+				}
+				actions[k] = name
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+func TestApplyKeys(t *testing.T) {
+	tests := []struct {
+		name    string
+		keys    map[string][]string
+		wantErr bool
+	}{
+		{"defaults", nil, false},
+		{"rebound", map[string][]string{"search_backward": {"ctrl+r"}, "quit": {"q", "x"}}, false},
+		{"turned off", map[string][]string{"select_screen": {}}, false},
+		{"freed by turning off", map[string][]string{"quit": {}, "help": {"q"}}, false},
+		{"same key on another screen", map[string][]string{"follow_latest": {"t"}}, false},
+		{"unknown action", map[string][]string{"explode": {"x"}}, true},
+		{"conflict in the log", map[string][]string{"timestamps": {"q"}}, true},
+		{"conflict with the viewport", map[string][]string{"save": {"j"}}, true},
+		{"conflict in the stage panel", map[string][]string{"show_stage": {"tab"}}, true},
+		{"conflict in the history", map[string][]string{"open_build": {"h"}}, true},
+		{"conflict with moving in the history", map[string][]string{"follow_latest": {"f"}}, true},
+		{"conflict in the prompt", map[string][]string{"prompt_cancel": {"ctrl+c"}}, true},
+	}
+	for _, tt := range tests {
+		app, log := defaultKeyMap(), jlsviewport.DefaultKeyMap()
+		if err := applyKeys(tt.keys, &app, &log); (err != nil) != tt.wantErr {
+			t.Errorf("%s: applyKeys(%v) = %v, want error %v", tt.name, tt.keys, err, tt.wantErr)
+		}
+	}
+}
+
+func TestApplyKeysRebinds(t *testing.T) {
+	app, log := defaultKeyMap(), jlsviewport.DefaultKeyMap()
+	keys := map[string][]string{"search_backward": {"ctrl+r", "R"}, "select_screen": {}}
+	if err := applyKeys(keys, &app, &log); err != nil {
+		t.Fatal(err)
+	}
+	if got := app.SearchBackward.Keys(); !slices.Equal(got, []string{"ctrl+r", "R"}) {
+		t.Errorf("search_backward is bound to %q, want ctrl+r and R", got)
+	}
+	if got := app.SearchBackward.Help().Key; got != "ctrl+r/R" {
+		t.Errorf("the help shows search_backward as %q, want ctrl+r/R", got)
+	}
+	if log.SelectScreen.Enabled() {
+		t.Errorf("select_screen is still on")
+	}
+}
+
+func TestKeyContexts(t *testing.T) {
+	app, log := defaultKeyMap(), jlsviewport.DefaultKeyMap()
+	bindings := keyBindings(&app, &log)
+	checked := make(map[string]bool)
+	for _, c := range keyContexts {
+		for _, name := range c.actions {
+			if _, ok := bindings[name]; !ok {
+				t.Errorf("the %s has unknown action %q", c.name, name)
+			}
+			checked[name] = true
+		}
+	}
+	for name := range bindings {
+		if !checked[name] {
+			t.Errorf("action %q isn't on any screen, so its keys aren't checked for conflicts", name)
+		}
+	}
+}
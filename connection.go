@@ -0,0 +1,84 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+const (
+	// refreshSeconds is the normal delay between job status updates
+	refreshSeconds = 5
+	// maxBackoff caps the delay between retries while Jenkins is unreachable
+	maxBackoff = 2 * time.Minute
+	// offlineAfter is the number of consecutive failures before the connection
+	// is shown as offline rather than retrying
+	offlineAfter = 3
+)
+
+// connection tracks the health of the connection to Jenkins so polling can
+// back off while it is unreachable.
+type connection struct {
+	failures     int
+	lastSuccess  time.Time
+	offlineSince time.Time
+	// failedPoll is set once a request of the current poll has failed, so
+	// the status and log requests of one poll count as a single failure
+	failedPoll bool
+}
+
+// poll starts a new round of requests to Jenkins.
+func (c *connection) poll() {
+	c.failedPoll = false
+}
+
+func (c *connection) succeeded(now time.Time) {
+	c.failures = 0
+	c.lastSuccess = now
+	c.offlineSince = time.Time{}
+}
+
+// failed records a failed request and returns how many seconds to wait
+// before trying again. Only the first failure of a poll counts, for later
+// ones ok is false and the wait already set stands.
+func (c *connection) failed(now time.Time) (seconds int, ok bool) {
+	if c.failedPoll && c.failures > 0 {
+		return 0, false
+	}
+	c.failedPoll = true
+	if c.failures == 0 {
+		c.offlineSince = now
+	}
+	c.failures++
+	return int(backoff(c.failures).Seconds()), true
+}
+
+// backoff doubles the refresh delay for every consecutive failure, with up to
+// 25% jitter so several sessions don't retry in lockstep.
+func backoff(failures int) time.Duration {
+	delay := refreshSeconds * time.Second
+	for i := 1; i < failures && delay < maxBackoff; i++ {
+		delay *= 2
+	}
+	delay = min(delay, maxBackoff)
+	jitter := time.Duration(rand.Int63n(int64(delay / 4)))
+	return delay - delay/8 + jitter
+}
+
+// status describes the connection state for the footer.
+func (c connection) status(secondsLeft int) string {
+	updated := ""
+	if !c.lastSuccess.IsZero() {
+		updated = "  Updated " + c.lastSuccess.Format(time.TimeOnly)
+	}
+	switch {
+	case c.failures >= offlineAfter:
+		return fmt.Sprintf("Offline since %s, retrying in %ds%s", c.offlineSince.Format("15:04"), secondsLeft, updated)
+	case c.failures > 0:
+		return fmt.Sprintf("Retrying in %ds%s", secondsLeft, updated)
+	case c.lastSuccess.IsZero():
+		return "Connecting"
+	default:
+		return fmt.Sprintf("Connected%s  Refresh in %d", updated, secondsLeft)
+	}
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	now := time.Now()
+	var c connection
+	// Doubling from refreshSeconds up to maxBackoff, with up to 1/8 either way
+	for _, want := range []int{5, 10, 20, 40, 80, 120, 120, 120} {
+		c.poll()
+		seconds, ok := c.failed(now)
+		if !ok {
+			t.Fatalf("failure %d wasn't counted", c.failures)
+		}
+		if low, high := want-want/8-1, want+want/8; seconds < low || seconds > high {
+			t.Errorf("after %d failures, waits %ds, want %ds to %ds", c.failures, seconds, low, high)
+		}
+	}
+
+	c.succeeded(now)
+	c.poll()
+	if seconds, _ := c.failed(now); seconds > refreshSeconds+refreshSeconds/8 {
+		t.Errorf("after a success, the first failure waits %ds, want about %ds", seconds, refreshSeconds)
+	}
+}
+
+func TestFailedOncePerPoll(t *testing.T) {
+	now := time.Now()
+	var c connection
+	c.poll()
+	if _, ok := c.failed(now); !ok {
+		t.Fatal("the first failure of a poll wasn't counted")
+	}
+	// The log request fails too
+	if _, ok := c.failed(now); ok || c.failures != 1 {
+		t.Errorf("the second failure of a poll was counted, %d failures", c.failures)
+	}
+	c.poll()
+	if _, ok := c.failed(now); !ok || c.failures != 2 {
+		t.Errorf("the failure of the next poll wasn't counted, %d failures", c.failures)
+	}
+
+	// The status request succeeds and the log request fails
+	c.succeeded(now)
+	if _, ok := c.failed(now); !ok || c.failures != 1 {
+		t.Errorf("a failure after a success wasn't counted, %d failures", c.failures)
+	}
+}
+
+func TestConnectionStatus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 9, 30, 0, 0, time.Local)
+	var c connection
+	if got := c.status(5); got != "Connecting" {
+		t.Errorf("before any request, status is %q", got)
+	}
+	c.succeeded(now)
+	if got, want := c.status(4), "Connected  Updated 09:30:00  Refresh in 4"; got != want {
+		t.Errorf("connected, status is %q, want %q", got, want)
+	}
+	for i := 0; i < offlineAfter; i++ {
+		c.poll()
+		c.failed(now.Add(time.Minute))
+	}
+	if got, want := c.status(30), "Offline since 09:31, retrying in 30s  Updated 09:30:00"; got != want {
+		t.Errorf("offline, status is %q, want %q", got, want)
+	}
+}
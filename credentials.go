@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+)
+
+// credentialOptions are the command line options for logging in.
+type credentialOptions struct {
+	User         string
+	Token        string
+	TokenFile    string
+	TokenCommand string
+	// TokenEnv is the JENKINS_TOKEN environment variable, which the token
+	// options win over
+	TokenEnv string
+}
+
+// credentials are the user and API token to log in to Jenkins with.
+type credentials struct {
+	user  string
+	token string
+	// source describes where the token came from, for the debug log
+	source string
+}
+
+// findCredentials works out how to log in to the job at jobUrl. The token
+// comes from the first of these that is set:
+//
+//  1. --token
+//  2. --token-file
+//  3. --token-command
+//  4. the JENKINS_TOKEN environment variable
+//  5. the token of the server in the config file
+//  6. the entry for the job's host in ~/.netrc, which may give the user too
+//
+// --user, or the JENKINS_USER environment variable, wins over the user of
+// the server. Without a token, Jenkins is accessed anonymously.
+func findCredentials(opts credentialOptions, server serverConfig, jobUrl string) (credentials, error) {
+	c := credentials{user: opts.User}
+	if c.user == "" {
+		c.user = server.User
+	}
+	var err error
+	switch {
+	case opts.Token != "":
+		c.token, c.source = opts.Token, "--token"
+	case opts.TokenFile != "":
+		c.token, err = readTokenFile(opts.TokenFile)
+		c.source = "--token-file " + opts.TokenFile
+	case opts.TokenCommand != "":
+		c.token, err = runTokenCommand(opts.TokenCommand)
+		c.source = "--token-command"
+	case opts.TokenEnv != "":
+		c.token, c.source = opts.TokenEnv, "$JENKINS_TOKEN"
+	default:
+		c.token, c.source, err = server.credential()
+	}
+	if err != nil || c.token != "" {
+		return c, err
+	}
+
+	c.source = "none, accessing Jenkins anonymously"
+	path := netrcPath()
+	u, err := url.Parse(jobUrl)
+	if err != nil || path == "" {
+		return c, nil
+	}
+	login, password, ok, err := netrcLookup(path, u.Hostname(), c.user)
+	if err != nil {
+		return c, fmt.Errorf("reading %s: %w", path, err)
+	}
+	if ok {
+		c.token, c.source = password, path
+		if c.user == "" {
+			c.user = login
+		}
+	}
+	return c, nil
+}
+
+// authSchemes are the values of --auth and the auth setting of servers
+var authSchemes = []string{"basic", "bearer", "cookie"}
+
+// auth returns how to log in with the credentials using scheme, one of the
+// authSchemes, or nil without a token.
+func (c credentials) auth(scheme string) (jenkins.Auth, error) {
+	if !slices.Contains(authSchemes, scheme) {
+		return nil, fmt.Errorf("invalid auth %q: expected one of %s", scheme, strings.Join(authSchemes, ", "))
+	}
+	if c.token == "" {
+		if scheme != "basic" {
+			return nil, fmt.Errorf("%s auth needs a token", scheme)
+		}
+		return nil, nil
+	}
+	switch scheme {
+	case "bearer":
+		return jenkins.BearerAuth{Token: c.token}, nil
+	case "cookie":
+		return jenkins.CookieAuth{Cookie: c.token}, nil
+	}
+	return jenkins.BasicAuth{User: c.user, Token: c.token}, nil
+}
+
+// credential returns the token of a server from the config file, and where
+// it came from. At most one source may be set.
+func (s serverConfig) credential() (token, source string, err error) {
+	set := 0
+	for _, value := range []string{s.Token, s.TokenEnv, s.TokenFile, s.TokenCommand} {
+		if value != "" {
+			set++
+		}
+	}
+	if set > 1 {
+		return "", "", fmt.Errorf("server %q: set only one of token, token_env, token_file and token_command", s.name)
+	}
+	where := fmt.Sprintf("server %q in the config file", s.name)
+	switch {
+	case s.Token != "":
+		return s.Token, "token of " + where, nil
+	case s.TokenEnv != "":
+		return os.Getenv(s.TokenEnv), fmt.Sprintf("$%s, from %s", s.TokenEnv, where), nil
+	case s.TokenFile != "":
+		token, err := readTokenFile(s.TokenFile)
+		return token, fmt.Sprintf("%s, from %s", s.TokenFile, where), err
+	case s.TokenCommand != "":
+		token, err := runTokenCommand(s.TokenCommand)
+		return token, "token_command of " + where, err
+	}
+	return "", "", nil
+}
+
+// readTokenFile reads a token from the first line of a file.
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("reading token: %w", err)
+	}
+	token, _, _ := strings.Cut(string(data), "\n")
+	return strings.TrimSpace(token), nil
+}
+
+// runTokenCommand runs a command with the shell, such as a password manager,
+// and reads the token from the first line of its output. The command can
+// prompt on the terminal, which its standard input and error are left on.
+func runTokenCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var out bytes.Buffer
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, &out, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("running token command %q: %w", command, err)
+	}
+	token, _, _ := strings.Cut(out.String(), "\n")
+	return strings.TrimSpace(token), nil
+}
+
+// netrcPath returns the path of the .netrc file, which $NETRC can change.
+func netrcPath() string {
+	if path := os.Getenv("NETRC"); path != "" {
+		return path
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".netrc")
+}
+
+// netrcLookup finds the login and password for host in the .netrc file at
+// path, falling back to the default entry. If user is set, only an entry
+// for that login matches. A missing file has no entries.
+func netrcLookup(path, host, user string) (login, password string, ok bool, err error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", "", false, nil
+	} else if err != nil {
+		return "", "", false, err
+	}
+
+	type entry struct {
+		machine, login, password string
+		isDefault                bool
+	}
+	var entries []entry
+	fields := netrcFields(string(data))
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				entries = append(entries, entry{machine: fields[i]})
+			}
+		case "default":
+			entries = append(entries, entry{isDefault: true})
+		case "login", "password", "account":
+			if i+1 >= len(fields) || len(entries) == 0 {
+				continue
+			}
+			i++
+			e := &entries[len(entries)-1]
+			if fields[i-1] == "login" {
+				e.login = fields[i]
+			} else if fields[i-1] == "password" {
+				e.password = fields[i]
+			}
+		}
+	}
+	for _, e := range entries {
+		if (e.isDefault || strings.EqualFold(e.machine, host)) && (user == "" || e.login == user) {
+			return e.login, e.password, e.password != "", nil
+		}
+	}
+	return "", "", false, nil
+}
+
+// netrcFields splits a .netrc file into its tokens, leaving out macro
+// definitions, which run until the next empty line.
+func netrcFields(data string) []string {
+	var fields []string
+	inMacro := false
+	for _, line := range strings.Split(data, "\n") {
+		if inMacro {
+			inMacro = strings.TrimSpace(line) != ""
+			continue
+		}
+		for _, f := range strings.Fields(line) {
+			if f == "macdef" {
+				inMacro = true
+				break
+			}
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+)
+
+func TestNetrcLookup(t *testing.T) {
+	const netrc = `machine ci.example.com login alice password secret1
+machine ci.example.com
+  login bob
+  password secret2
+macdef init
+machine macro.example.com login mallory password nope
+
+machine CI.Other.com login carol password secret3 account ops
+machine nopassword.example.com login dave
+default login anonymous password guest
+`
+	path := filepath.Join(t.TempDir(), ".netrc")
+	if err := os.WriteFile(path, []byte(netrc), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name         string
+		host         string
+		user         string
+		wantLogin    string
+		wantPassword string
+		wantOk       bool
+	}{
+		{"first entry", "ci.example.com", "", "alice", "secret1", true},
+		{"entry for the user", "ci.example.com", "bob", "bob", "secret2", true},
+		{"host case", "ci.other.com", "", "carol", "secret3", true},
+		{"default", "elsewhere.example.com", "", "anonymous", "guest", true},
+		{"no entry for the user", "ci.example.com", "erin", "", "", false},
+		{"macro", "macro.example.com", "mallory", "", "", false},
+		{"no password", "nopassword.example.com", "dave", "dave", "", false},
+	}
+	for _, tt := range tests {
+		login, password, ok, err := netrcLookup(path, tt.host, tt.user)
+		if err != nil || login != tt.wantLogin || password != tt.wantPassword || ok != tt.wantOk {
+			t.Errorf("%s: netrcLookup(%q, %q) = %q, %q, %v, %v, want %q, %q, %v", tt.name, tt.host, tt.user,
+				login, password, ok, err, tt.wantLogin, tt.wantPassword, tt.wantOk)
+		}
+	}
+
+	login, password, ok, err := netrcLookup(filepath.Join(t.TempDir(), "missing"), "ci.example.com", "")
+	if login != "" || password != "" || ok || err != nil {
+		t.Errorf("netrcLookup of a missing file = %q, %q, %v, %v, want no entry", login, password, ok, err)
+	}
+}
+
+func TestFindCredentials(t *testing.T) {
+	t.Setenv("NETRC", filepath.Join(t.TempDir(), "missing"))
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("from-file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := serverConfig{name: "ci", Token: "from-config"}
+	tests := []struct {
+		name       string
+		opts       credentialOptions
+		wantToken  string
+		wantSource string
+	}{
+		{"--token", credentialOptions{Token: "flag", TokenFile: tokenFile, TokenEnv: "env"}, "flag", "--token"},
+		{"--token-file over the environment", credentialOptions{TokenFile: tokenFile, TokenEnv: "env"}, "from-file", "--token-file " + tokenFile},
+		{"--token-command over the environment", credentialOptions{TokenCommand: "echo from-command", TokenEnv: "env"}, "from-command", "--token-command"},
+		{"environment over the config file", credentialOptions{TokenEnv: "env"}, "env", "$JENKINS_TOKEN"},
+		{"config file", credentialOptions{}, "from-config", `token of server "ci" in the config file`},
+	}
+	for _, tt := range tests {
+		c, err := findCredentials(tt.opts, server, "https://ci.example.com/job/x/")
+		if err != nil || c.token != tt.wantToken || c.source != tt.wantSource {
+			t.Errorf("%s: findCredentials = %q from %q, %v, want %q from %q", tt.name, c.token, c.source, err, tt.wantToken, tt.wantSource)
+		}
+	}
+}
+
+func TestCredentialsAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		creds   credentials
+		scheme  string
+		want    jenkins.Auth
+		wantErr string
+	}{
+		{"basic", credentials{user: "me", token: "t"}, "basic", jenkins.BasicAuth{User: "me", Token: "t"}, ""},
+		{"bearer", credentials{token: "t"}, "bearer", jenkins.BearerAuth{Token: "t"}, ""},
+		{"cookie", credentials{token: "S=1"}, "cookie", jenkins.CookieAuth{Cookie: "S=1"}, ""},
+		{"anonymous", credentials{}, "basic", nil, ""},
+		{"bearer without a token", credentials{}, "bearer", nil, "needs a token"},
+		{"unknown scheme", credentials{token: "t"}, "digest", nil, "invalid auth"},
+		{"unknown scheme without a token", credentials{}, "Bearer", nil, "invalid auth"},
+	}
+	for _, tt := range tests {
+		got, err := tt.creds.auth(tt.scheme)
+		errText := ""
+		if err != nil {
+			errText = err.Error()
+		}
+		if got != tt.want || (tt.wantErr == "") != (err == nil) || !strings.Contains(errText, tt.wantErr) {
+			t.Errorf("%s: auth(%q) = %#v, %v, want %#v, error %q", tt.name, tt.scheme, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import "strings"
+
+// repeatedFlag collects the values of a flag that may be repeated, such as
+// --header or --error-pattern. Unlike a string slice flag, it doesn't split
+// them at commas, which headers and regular expressions can contain.
+type repeatedFlag []string
+
+func (f *repeatedFlag) Set(value string) error {
+	*f = append(*f, value)
+	return nil
+}
+
+func (f *repeatedFlag) String() string {
+	return strings.Join(*f, ", ")
+}
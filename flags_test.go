@@ -0,0 +1,38 @@
+package main
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/urfave/cli/v2"
+)
+
+func TestRepeatedFlag(t *testing.T) {
+	var got []string
+	app := &cli.App{
+		Flags: []cli.Flag{&cli.GenericFlag{Name: "error-pattern", Value: &repeatedFlag{}}},
+		Action: func(cCtx *cli.Context) error {
+			got = *cCtx.Generic("error-pattern").(*repeatedFlag)
+			return nil
+		},
+	}
+	args := []string{"jenkins-log-streamer", "--error-pattern", `x(a,b)`, "--error-pattern", `fail{1,3}`}
+	if err := app.Run(args); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{`x(a,b)`, `fail{1,3}`}
+	if !slices.Equal(got, want) {
+		t.Fatalf("--error-pattern values are %q, want %q", got, want)
+	}
+
+	markers, err := problemMarkers(got, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	patterns := markers[errorMarker].Patterns
+	for i, line := range []string{"xa,b", "faill"} {
+		if !patterns[i].MatchString(line) {
+			t.Errorf("pattern %q doesn't match %q", patterns[i], line)
+		}
+	}
+}
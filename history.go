@@ -0,0 +1,124 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/bubbles/table"
+	tea "github.com/charmbracelet/bubbletea"
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// historyLimit is the number of recent builds listed in the build history
+const historyLimit = 50
+
+type buildsMsg struct {
+	builds []jenkins.Build
+	err    error
+}
+
+// fetchBuilds fetches the recent builds of the job. It fails on its own,
+// without backing off the log.
+func fetchBuilds(ctx context.Context, client *jenkins.Client) tea.Cmd {
+	return func() tea.Msg {
+		builds, err := client.FetchBuilds(ctx, historyLimit)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return buildsMsg{builds: builds, err: err}
+	}
+}
+
+// history is the build history screen, listing the job's recent builds.
+type history struct {
+	table  table.Model
+	builds []jenkins.Build
+}
+
+// newHistory returns the build history, moving through the builds with the
+// keys that scroll the log.
+func newHistory(keys jlsviewport.KeyMap) history {
+	return history{
+		table: table.New(table.WithColumns(historyColumns(80)), table.WithFocused(true), table.WithKeyMap(table.KeyMap{
+			LineUp:       keys.Up,
+			LineDown:     keys.Down,
+			PageUp:       keys.PageUp,
+			PageDown:     keys.PageDown,
+			HalfPageUp:   keys.HalfPageUp,
+			HalfPageDown: keys.HalfPageDown,
+			GotoTop:      keys.GotoTop,
+			GotoBottom:   keys.GotoBottom,
+		})),
+	}
+}
+
+// historyColumns sizes the columns to fit width, giving the cause whatever
+// is left over.
+func historyColumns(width int) []table.Column {
+	columns := []table.Column{
+		{Title: "Build", Width: 7},
+		{Title: "Result", Width: 11},
+		{Title: "Started", Width: 16},
+		{Title: "Duration", Width: 10},
+		{Title: "Cause", Width: 0},
+	}
+	// Every cell is padded by one space on each side
+	remaining := width - 2*len(columns)
+	for _, c := range columns {
+		remaining -= c.Width
+	}
+	columns[len(columns)-1].Width = max(10, remaining)
+	return columns
+}
+
+func (h *history) setSize(width, height int) {
+	h.table.SetColumns(historyColumns(width))
+	h.table.SetWidth(width)
+	// The table header takes one line
+	h.table.SetHeight(max(1, height-1))
+}
+
+// setBuilds replaces the listed builds, keeping the same build selected.
+func (h *history) setBuilds(builds []jenkins.Build) {
+	selected, hadSelection := h.selected()
+	h.builds = builds
+	rows := make([]table.Row, len(builds))
+	cursor := h.table.Cursor()
+	for i, b := range builds {
+		rows[i] = buildRow(b)
+		if hadSelection && b.Number == selected.Number {
+			cursor = i
+		}
+	}
+	h.table.SetRows(rows)
+	h.table.SetCursor(cursor)
+}
+
+// selected returns the build under the cursor.
+func (h history) selected() (jenkins.Build, bool) {
+	cursor := h.table.Cursor()
+	if cursor < 0 || cursor >= len(h.builds) {
+		return jenkins.Build{}, false
+	}
+	return h.builds[cursor], true
+}
+
+func buildRow(b jenkins.Build) table.Row {
+	started := time.UnixMilli(b.Timestamp)
+	result := b.Result
+	duration := time.Duration(b.Duration) * time.Millisecond
+	if b.Building {
+		result = "In Progress"
+		duration = time.Since(started)
+	}
+	return table.Row{
+		"#" + strconv.Itoa(b.Number),
+		result,
+		started.Format("2006-01-02 15:04"),
+		formatDuration(duration),
+		b.Cause(),
+	}
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+func historyBuilds(numbers ...int) []jenkins.Build {
+	builds := make([]jenkins.Build, len(numbers))
+	for i, n := range numbers {
+		builds[i] = jenkins.Build{Number: n, Result: "SUCCESS"}
+	}
+	return builds
+}
+
+func TestSetBuilds(t *testing.T) {
+	h := newHistory(jlsviewport.DefaultKeyMap())
+	h.setSize(80, 10)
+	h.setBuilds(historyBuilds(42, 41, 40))
+	h.table.MoveDown(1)
+	// A new build starts, moving the others down
+	h.setBuilds(historyBuilds(43, 42, 41, 40))
+	if b, ok := h.selected(); !ok || b.Number != 41 {
+		t.Errorf("selected build %d, %v, want 41", b.Number, ok)
+	}
+	h.setBuilds(nil)
+	if b, ok := h.selected(); ok {
+		t.Errorf("selected build %d of none", b.Number)
+	}
+}
+
+func TestBuildRow(t *testing.T) {
+	started := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	b := jenkins.Build{Number: 42, Result: "FAILURE", Timestamp: started.UnixMilli(), Duration: 754321}
+	want := []string{"#42", "FAILURE", "2024-05-01 09:00", formatDuration(754321 * time.Millisecond), ""}
+	if got := buildRow(b); !slices.Equal(got, want) {
+		t.Errorf("buildRow = %q, want %q", got, want)
+	}
+	b.Building, b.Result = true, ""
+	if got := buildRow(b); got[1] != "In Progress" {
+		t.Errorf("building, result is %q, want In Progress", got[1])
+	}
+}
+
+func TestSwitchBuilds(t *testing.T) {
+	newModel := func() model {
+		m := model{build: "41", keys: defaultKeyMap(), history: newHistory(jlsviewport.DefaultKeyMap()), showHistory: true}
+		m.history.setSize(80, 10)
+		m.history.setBuilds(historyBuilds(43, 42, 41))
+		return m
+	}
+	press := func(m model, keys ...tea.KeyMsg) (model, tea.Cmd) {
+		var cmd tea.Cmd
+		for _, k := range keys {
+			var updated tea.Model
+			updated, cmd = m.updateHistory(k)
+			m = updated.(model)
+		}
+		return m, cmd
+	}
+	down := tea.KeyMsg{Type: tea.KeyDown}
+	enter := tea.KeyMsg{Type: tea.KeyEnter}
+	follow := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("F")}
+	pageDown := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("f")}
+	esc := tea.KeyMsg{Type: tea.KeyEsc}
+
+	tests := []struct {
+		name        string
+		keys        []tea.KeyMsg
+		wantBuild   string
+		wantHistory bool
+		wantFetch   bool
+	}{
+		{"open the selected build", []tea.KeyMsg{down, enter}, "42", false, true},
+		{"follow the latest build", []tea.KeyMsg{down, follow}, jenkins.LastBuild, false, true},
+		{"back to the log", []tea.KeyMsg{down, esc}, "41", false, false},
+		{"move the cursor", []tea.KeyMsg{down, down}, "41", true, false},
+		{"page down", []tea.KeyMsg{pageDown}, "41", true, false},
+	}
+	for _, tt := range tests {
+		m, cmd := press(newModel(), tt.keys...)
+		if m.build != tt.wantBuild || m.showHistory != tt.wantHistory || (cmd != nil) != tt.wantFetch {
+			t.Errorf("%s: build %q, history shown %v, fetching %v, want %q, %v, %v", tt.name,
+				m.build, m.showHistory, cmd != nil, tt.wantBuild, tt.wantHistory, tt.wantFetch)
+		}
+	}
+}
+
+func TestHistoryKeys(t *testing.T) {
+	app, log := defaultKeyMap(), jlsviewport.DefaultKeyMap()
+	if err := applyKeys(map[string][]string{"down": {"ctrl+n"}, "goto_bottom": {">"}}, &app, &log); err != nil {
+		t.Fatal(err)
+	}
+	h := newHistory(log)
+	h.setSize(80, 10)
+	h.setBuilds(historyBuilds(43, 42, 41, 40))
+	for _, k := range []tea.KeyMsg{{Type: tea.KeyCtrlN}, {Type: tea.KeyRunes, Runes: []rune("j")}} {
+		h.table, _ = h.table.Update(k)
+	}
+	if got := h.table.Cursor(); got != 1 {
+		t.Errorf("after ctrl+n and j, the cursor is on row %d, want 1", got)
+	}
+	h.table, _ = h.table.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune(">")})
+	if got := h.table.Cursor(); got != 3 {
+		t.Errorf("after >, the cursor is on row %d, want 3", got)
+	}
+}
@@ -0,0 +1,300 @@
+// Package ansi measures, wraps and strips text containing terminal escape
+// sequences, such as SGR colors and OSC 8 hyperlinks.
+package ansi
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	esc = '\x1b'
+	bel = '\a'
+)
+
+// Reset clears all SGR attributes.
+const Reset = "\x1b[0m"
+
+// LinkEnd ends an OSC 8 hyperlink.
+const LinkEnd = "\x1b]8;;\x1b\\"
+
+// LinkStart starts an OSC 8 hyperlink to url.
+func LinkStart(url string) string {
+	return "\x1b]8;;" + url + "\x1b\\"
+}
+
+// SequenceEnd returns the index just past the escape sequence starting at
+// s[i], which must be an ESC. It returns false if s ends before the sequence
+// does.
+func SequenceEnd(s string, i int) (int, bool) {
+	if i+1 >= len(s) {
+		return len(s), false
+	}
+	switch s[i+1] {
+	case '[':
+		// CSI: parameter and intermediate bytes, then a final byte
+		for j := i + 2; j < len(s); j++ {
+			if s[j] >= 0x40 && s[j] <= 0x7e {
+				return j + 1, true
+			}
+		}
+		return len(s), false
+	case ']', 'P', '_', '^', 'X':
+		// OSC and other strings, ended by BEL or ST (ESC \)
+		for j := i + 2; j < len(s); j++ {
+			if s[j] == bel {
+				return j + 1, true
+			}
+			if s[j] == esc {
+				if j+1 >= len(s) {
+					return len(s), false
+				}
+				if s[j+1] == '\\' {
+					return j + 2, true
+				}
+			}
+		}
+		return len(s), false
+	default:
+		// Two character sequences, possibly with intermediate bytes
+		for j := i + 1; j < len(s); j++ {
+			if s[j] < 0x20 || s[j] > 0x2f {
+				return j + 1, true
+			}
+		}
+		return len(s), false
+	}
+}
+
+// Strip removes all escape sequences from s.
+func Strip(s string) string {
+	if !strings.ContainsRune(s, esc) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			i, _ = SequenceEnd(s, i)
+			continue
+		}
+		j := strings.IndexByte(s[i:], esc)
+		if j < 0 {
+			j = len(s) - i
+		}
+		b.WriteString(s[i : i+j])
+		i += j
+	}
+	return b.String()
+}
+
+// Width returns the number of terminal cells needed to print s.
+func Width(s string) int {
+	return runewidth.StringWidth(Strip(ExpandTabs(s)))
+}
+
+// tabWidth is the distance between tab stops.
+const tabWidth = 8
+
+// ExpandTabs replaces the tabs in s with spaces up to the next tab stop, so
+// s takes up as many cells as it's measured to. Terminals would otherwise
+// move to a tab stop counted from the edge of the screen, not from the start
+// of s, and nothing that measures s would know how far.
+func ExpandTabs(s string) string {
+	if !strings.ContainsRune(s, '\t') {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + 8)
+	column := 0
+	for i := 0; i < len(s); {
+		switch s[i] {
+		case esc:
+			end, _ := SequenceEnd(s, i)
+			b.WriteString(s[i:end])
+			i = end
+		case '\t':
+			n := tabWidth - column%tabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			column += n
+			i++
+		default:
+			r, size := utf8.DecodeRuneInString(s[i:])
+			b.WriteString(s[i : i+size])
+			column += runewidth.RuneWidth(r)
+			i += size
+		}
+	}
+	return b.String()
+}
+
+// Wrap splits s into rows no wider than width cells, with its tabs expanded
+// to spaces. Escape sequences are kept with the text that follows them, and
+// colors and hyperlinks split across rows are closed at the end of one row
+// and opened again on the next.
+//
+// Rows are also kept narrow enough for renderers that measure escape
+// sequences naively. Bubble Tea, for example, counts most of an OSC 8 URL as
+// printable text and truncates lines it thinks are too wide, which can cut a
+// hyperlink in half. Rows that can't be made to fit lose their hyperlinks.
+func Wrap(s string, width int) []string {
+	s = ExpandTabs(s)
+	if width <= 0 {
+		return []string{s}
+	}
+	var rows []string
+	var row strings.Builder
+	rowWidth := 0
+	// naive measures the row as a naive renderer would, and fresh what a new
+	// row starts with, to tell whether starting a new row would help
+	var naive naiveWidth
+	link := ""
+	var style Style
+	// reopen is what a new row starts with to continue the current row
+	reopen := func() string {
+		if link != "" {
+			return style.Sequence() + LinkStart(link)
+		}
+		return style.Sequence()
+	}
+	fresh := func(next string) int {
+		var n naiveWidth
+		n.add(reopen())
+		n.add(next)
+		return n.width
+	}
+	flush := func() {
+		if link != "" {
+			row.WriteString(LinkEnd)
+		}
+		if !style.IsDefault() {
+			row.WriteString(Reset)
+		}
+		rows = append(rows, fitNaive(row.String(), width))
+		row.Reset()
+		rowWidth = 0
+		naive = naiveWidth{}
+		row.WriteString(reopen())
+		naive.add(reopen())
+	}
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			end, _ := SequenceEnd(s, i)
+			trial := naive
+			trial.add(s[i:end])
+			if trial.width > width && rowWidth > 0 && fresh(s[i:end]) <= width {
+				flush()
+				naive.add(s[i:end])
+			} else {
+				naive = trial
+			}
+			if url, ok := linkTarget(s[i:end]); ok {
+				link = url
+			}
+			style.Apply(s[i:end])
+			row.WriteString(s[i:end])
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w := runewidth.RuneWidth(r)
+		trial := naive
+		trial.add(s[i : i+size])
+		tooWide := rowWidth+w > width || (trial.width > width && fresh(s[i:i+size]) <= width)
+		if tooWide && rowWidth > 0 {
+			flush()
+			naive.add(s[i : i+size])
+		} else {
+			naive = trial
+		}
+		row.WriteString(s[i : i+size])
+		rowWidth += w
+		i += size
+	}
+	flush()
+	return rows
+}
+
+// linkTarget returns the URL of an OSC 8 hyperlink sequence, which is empty
+// for the sequence that ends a link.
+func linkTarget(seq string) (string, bool) {
+	if !strings.HasPrefix(seq, "\x1b]8;") {
+		return "", false
+	}
+	seq = strings.TrimSuffix(strings.TrimSuffix(seq, "\x1b\\"), "\a")
+	// Skip the parameters between the first two semicolons
+	_, rest, _ := strings.Cut(seq[len("\x1b]8;"):], ";")
+	return rest, true
+}
+
+// naiveWidth measures text the way github.com/muesli/reflow does, treating
+// everything from an ESC up to the next letter as an escape sequence.
+type naiveWidth struct {
+	inSequence bool
+	width      int
+}
+
+func (n *naiveWidth) add(s string) {
+	for _, r := range s {
+		switch {
+		case r == esc:
+			n.inSequence = true
+		case n.inSequence:
+			n.inSequence = !((r >= 0x40 && r <= 0x5a) || (r >= 0x61 && r <= 0x7a))
+		default:
+			n.width += runewidth.RuneWidth(r)
+		}
+	}
+}
+
+// fitNaive removes hyperlinks from row if they make it look wider than width
+// to a naive renderer.
+func fitNaive(row string, width int) string {
+	var n naiveWidth
+	n.add(row)
+	if n.width <= width {
+		return row
+	}
+	var b strings.Builder
+	for i := 0; i < len(row); {
+		if row[i] == esc {
+			end, _ := SequenceEnd(row, i)
+			if !strings.HasPrefix(row[i:end], "\x1b]") {
+				b.WriteString(row[i:end])
+			}
+			i = end
+			continue
+		}
+		b.WriteByte(row[i])
+		i++
+	}
+	return b.String()
+}
+
+// Truncate cuts s down to at most width cells, with its tabs expanded to
+// spaces, keeping every escape sequence so colors and links are still closed.
+// Like Wrap, it drops hyperlinks a naive renderer would think are too wide.
+func Truncate(s string, width int) string {
+	s = ExpandTabs(s)
+	var b strings.Builder
+	b.Grow(len(s))
+	total := 0
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			end, _ := SequenceEnd(s, i)
+			b.WriteString(s[i:end])
+			i = end
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		w := runewidth.RuneWidth(r)
+		if total+w <= width {
+			b.WriteString(s[i : i+size])
+			total += w
+		}
+		i += size
+	}
+	return fitNaive(b.String(), width)
+}
@@ -0,0 +1,114 @@
+package ansi
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestWrap(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  []string
+	}{
+		{"fits", "abc", 3, []string{"abc"}},
+		{"empty", "", 3, []string{""}},
+		{"no width", "abcdef", 0, []string{"abcdef"}},
+		{"plain", "abcdefg", 3, []string{"abc", "def", "g"}},
+		{"wide characters", "日本語", 4, []string{"日本", "語"}},
+		{"color", "\x1b[31mabcd\x1b[0m", 2, []string{"\x1b[31mab\x1b[0m", "\x1b[31mcd\x1b[0m"}},
+		{
+			"hyperlink",
+			LinkStart("u") + "abcdef" + LinkEnd + " e", 4,
+			[]string{LinkStart("u") + "abcd" + LinkEnd, LinkStart("u") + "ef" + LinkEnd + " e"},
+		},
+		{
+			"hyperlink in color",
+			"\x1b[1mab" + LinkStart("u") + "cdef" + LinkEnd + Reset, 4,
+			[]string{"\x1b[1mab" + LinkStart("u") + "cd" + LinkEnd + Reset, "\x1b[1m" + LinkStart("u") + "ef" + LinkEnd + Reset},
+		},
+		{
+			// The URL counts as text to a naive renderer, so rows are cut early
+			"long hyperlink",
+			LinkStart("http://x/y") + "abcdef" + LinkEnd + " e", 12,
+			[]string{LinkStart("http://x/y") + "abcd" + LinkEnd, LinkStart("http://x/y") + "ef" + LinkEnd + " e"},
+		},
+		{
+			"leading tab",
+			"\tat com.example.Foo.bar(Foo.java:12)", 20,
+			[]string{"        at com.examp", "le.Foo.bar(Foo.java:", "12)"},
+		},
+		{"hyperlink too long to keep", LinkStart("http://x/y") + "abcd" + LinkEnd + " e", 3, []string{"abc", "d", " e"}},
+	}
+	for _, tt := range tests {
+		if got := Wrap(tt.s, tt.width); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Wrap(%q, %d) = %q, want %q", tt.name, tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestSequenceEnd(t *testing.T) {
+	tests := []struct {
+		s        string
+		end      int
+		complete bool
+	}{
+		{"\x1b[31mred", 5, true},
+		{"\x1b[38;5;2", 8, false},
+		{"\x1b]8;;u\x1b\\link", 8, true},
+		{"\x1b]8;;u\alink", 7, true},
+		{"\x1b]8;;u\x1b", 7, false},
+		{"\x1b(Babc", 3, true},
+		{"\x1b", 1, false},
+	}
+	for _, tt := range tests {
+		end, complete := SequenceEnd(tt.s, 0)
+		if end != tt.end || complete != tt.complete {
+			t.Errorf("SequenceEnd(%q, 0) = %d, %v, want %d, %v", tt.s, end, complete, tt.end, tt.complete)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	tests := []struct {
+		name  string
+		s     string
+		width int
+		want  string
+	}{
+		{"fits", "abc", 3, "abc"},
+		{"plain", "abcdef", 3, "abc"},
+		{"wide characters", "日本語", 5, "日本"},
+		{"keeps sequences", "\x1b[31mabcd\x1b[0m", 2, "\x1b[31mab\x1b[0m"},
+		{"leading tab", "\tat com.example.Foo.bar(Foo.java:12)", 20, "        at com.examp"},
+	}
+	for _, tt := range tests {
+		if got := Truncate(tt.s, tt.width); got != tt.want {
+			t.Errorf("%s: Truncate(%q, %d) = %q, want %q", tt.name, tt.s, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		s         string
+		want      string
+		wantWidth int
+	}{
+		{"no tabs", "no tabs", 7},
+		{"\tx", "        x", 9},
+		{"a\tb", "a       b", 9},
+		{"12345678\tx", "12345678        x", 17},
+		{"\x1b[31m\tx\x1b[0m", "\x1b[31m        x\x1b[0m", 9},
+		{"日\tx", "日      x", 9},
+	}
+	for _, tt := range tests {
+		if got := ExpandTabs(tt.s); got != tt.want {
+			t.Errorf("ExpandTabs(%q) = %q, want %q", tt.s, got, tt.want)
+		}
+		if got := Width(tt.s); got != tt.wantWidth {
+			t.Errorf("Width(%q) = %d, want %d", tt.s, got, tt.wantWidth)
+		}
+	}
+}
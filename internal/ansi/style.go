@@ -0,0 +1,199 @@
+package ansi
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Style is the SGR graphic rendition in effect at some point in text: the
+// attributes such as bold or underline, and the foreground and background
+// colors. The zero value is the terminal default.
+type Style struct {
+	// attributes has bit n set for SGR parameter n, from 1 (bold) to
+	// 9 (crossed out)
+	attributes uint16
+	// foreground and background hold the SGR parameters that set them,
+	// such as "31" or "38;5;208"
+	foreground string
+	background string
+}
+
+// IsDefault reports whether s is the terminal default style.
+func (s Style) IsDefault() bool {
+	return s == Style{}
+}
+
+// Sequence returns the SGR sequence that switches from the default style to
+// s, which is empty for the default style.
+func (s Style) Sequence() string {
+	if s.IsDefault() {
+		return ""
+	}
+	var params []string
+	for n := 1; n <= 9; n++ {
+		if s.attributes&(1<<n) != 0 {
+			params = append(params, strconv.Itoa(n))
+		}
+	}
+	if s.foreground != "" {
+		params = append(params, s.foreground)
+	}
+	if s.background != "" {
+		params = append(params, s.background)
+	}
+	return "\x1b[" + strings.Join(params, ";") + "m"
+}
+
+// Apply updates s with the escape sequence seq. Sequences other than SGR
+// leave s unchanged.
+func (s *Style) Apply(seq string) {
+	if !isSgr(seq) {
+		return
+	}
+	params := strings.Split(seq[2:len(seq)-1], ";")
+	for i := 0; i < len(params); i++ {
+		p := params[i]
+		if strings.Contains(p, ":") {
+			// Colors with sub-parameters, such as 38:5:208
+			if strings.HasPrefix(p, "38:") {
+				s.foreground = p
+			} else if strings.HasPrefix(p, "48:") {
+				s.background = p
+			}
+			continue
+		}
+		n, err := strconv.Atoi(p)
+		if p != "" && err != nil {
+			continue
+		}
+		switch {
+		case n == 0:
+			*s = Style{}
+		case n >= 1 && n <= 9:
+			s.attributes |= 1 << n
+		case n == 22:
+			s.attributes &^= 1<<1 | 1<<2
+		case n == 23:
+			s.attributes &^= 1 << 3
+		case n == 24:
+			s.attributes &^= 1 << 4
+		case n == 25:
+			s.attributes &^= 1<<5 | 1<<6
+		case n >= 27 && n <= 29:
+			s.attributes &^= 1 << (n - 20)
+		case n >= 30 && n <= 37, n >= 90 && n <= 97:
+			s.foreground = p
+		case n == 39:
+			s.foreground = ""
+		case n >= 40 && n <= 47, n >= 100 && n <= 107:
+			s.background = p
+		case n == 49:
+			s.background = ""
+		case n == 38 || n == 48:
+			// Extended colors: 5;n for 256 colors, 2;r;g;b for 24-bit
+			count := 0
+			if i+1 < len(params) && params[i+1] == "5" {
+				count = 2
+			} else if i+1 < len(params) && params[i+1] == "2" {
+				count = 4
+			}
+			if i+count >= len(params) {
+				// Incomplete, ignore the rest of the sequence
+				return
+			}
+			color := strings.Join(params[i:i+count+1], ";")
+			if n == 38 {
+				s.foreground = color
+			} else {
+				s.background = color
+			}
+			i += count
+		}
+	}
+}
+
+// StripStyles removes SGR sequences from s, keeping other escape sequences
+// such as hyperlinks.
+func StripStyles(s string) string {
+	if !strings.ContainsRune(s, esc) {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s))
+	for i := 0; i < len(s); {
+		if s[i] == esc {
+			end, _ := SequenceEnd(s, i)
+			if !isSgr(s[i:end]) {
+				b.WriteString(s[i:end])
+			}
+			i = end
+			continue
+		}
+		j := strings.IndexByte(s[i:], esc)
+		if j < 0 {
+			j = len(s) - i
+		}
+		b.WriteString(s[i : i+j])
+		i += j
+	}
+	return b.String()
+}
+
+func isSgr(seq string) bool {
+	return strings.HasPrefix(seq, "\x1b[") && strings.HasSuffix(seq, "m")
+}
+
+// Span is a range of bytes in text with its escape sequences removed, as
+// returned by Strip, and the SGR sequence to highlight it with.
+type Span struct {
+	Start int
+	End   int
+	Style string
+}
+
+// Highlight applies the styles of spans to s. The spans must be sorted and
+// must not overlap. Styles set by s itself are overridden inside a span and
+// restored after it.
+func Highlight(s string, spans []Span) string {
+	if len(spans) == 0 {
+		return s
+	}
+	var b strings.Builder
+	b.Grow(len(s) + len(spans)*16)
+	var style Style
+	k := 0
+	active := false
+	plain := 0
+	for i := 0; i < len(s); {
+		for k < len(spans) && !active && spans[k].End <= plain {
+			// Empty, or overlapping the previous span
+			k++
+		}
+		if s[i] == esc {
+			end, _ := SequenceEnd(s, i)
+			style.Apply(s[i:end])
+			b.WriteString(s[i:end])
+			if active && isSgr(s[i:end]) {
+				b.WriteString(spans[k].Style)
+			}
+			i = end
+			continue
+		}
+		if !active && k < len(spans) && spans[k].Start <= plain {
+			b.WriteString(spans[k].Style)
+			active = true
+		}
+		b.WriteByte(s[i])
+		i++
+		plain++
+		if active && plain >= spans[k].End {
+			b.WriteString(Reset + style.Sequence())
+			active = false
+			k++
+		}
+	}
+	if active {
+		b.WriteString(Reset + style.Sequence())
+	}
+	return b.String()
+}
@@ -0,0 +1,70 @@
+package ansi
+
+import "testing"
+
+func TestStyleApply(t *testing.T) {
+	tests := []struct {
+		name string
+		seqs []string
+		want string
+	}{
+		{"default", nil, ""},
+		{"color", []string{"\x1b[31m"}, "\x1b[31m"},
+		{"attributes and colors", []string{"\x1b[1;4;32;44m"}, "\x1b[1;4;32;44m"},
+		{"bright colors", []string{"\x1b[91;104m"}, "\x1b[91;104m"},
+		{"reset", []string{"\x1b[1;31m", "\x1b[0m"}, ""},
+		{"empty reset", []string{"\x1b[1;31m", "\x1b[m"}, ""},
+		{"reset in a sequence", []string{"\x1b[1m", "\x1b[0;32m"}, "\x1b[32m"},
+		{"bold off", []string{"\x1b[1;2;3m", "\x1b[22m"}, "\x1b[3m"},
+		{"default colors", []string{"\x1b[1;31;42m", "\x1b[39m", "\x1b[49m"}, "\x1b[1m"},
+		{"256 colors", []string{"\x1b[38;5;208;1m"}, "\x1b[1;38;5;208m"},
+		{"24-bit colors", []string{"\x1b[48;2;1;2;3m"}, "\x1b[48;2;1;2;3m"},
+		{"sub-parameters", []string{"\x1b[38:5:208m"}, "\x1b[38:5:208m"},
+		{"incomplete color", []string{"\x1b[1;38;5m"}, "\x1b[1m"},
+		{"other sequences", []string{"\x1b[31m", "\x1b[2K", LinkStart("u")}, "\x1b[31m"},
+	}
+	for _, tt := range tests {
+		var s Style
+		for _, seq := range tt.seqs {
+			s.Apply(seq)
+		}
+		if got := s.Sequence(); got != tt.want {
+			t.Errorf("%s: applying %q gives %q, want %q", tt.name, tt.seqs, got, tt.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	const inverse = "\x1b[7m"
+	tests := []struct {
+		name  string
+		s     string
+		spans []Span
+		want  string
+	}{
+		{"no spans", "hello world", nil, "hello world"},
+		{"plain", "hello world", []Span{{0, 5, inverse}}, inverse + "hello" + Reset + " world"},
+		{"to the end", "hello world", []Span{{6, 11, inverse}}, "hello " + inverse + "world" + Reset},
+		{
+			"two spans",
+			"a b c", []Span{{0, 1, inverse}, {4, 5, inverse}},
+			inverse + "a" + Reset + " b " + inverse + "c" + Reset,
+		},
+		{
+			"restores the style",
+			"\x1b[31mred text\x1b[0m", []Span{{4, 8, inverse}},
+			"\x1b[31mred " + inverse + "text" + Reset + "\x1b[31m" + Reset,
+		},
+		{
+			"overrides styles in the span",
+			"ab\x1b[32mcd", []Span{{0, 4, inverse}},
+			inverse + "ab\x1b[32m" + inverse + "cd" + Reset + "\x1b[32m",
+		},
+		{"empty span", "abc", []Span{{1, 1, inverse}, {2, 3, inverse}}, "ab" + inverse + "c" + Reset},
+	}
+	for _, tt := range tests {
+		if got := Highlight(tt.s, tt.spans); got != tt.want {
+			t.Errorf("%s: Highlight(%q, %v) = %q, want %q", tt.name, tt.s, tt.spans, got, tt.want)
+		}
+	}
+}
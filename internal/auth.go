@@ -0,0 +1,44 @@
+package jenkins
+
+import "net/http"
+
+// Auth adds credentials to each request the client sends. Implement it to
+// log in to Jenkins with another scheme.
+type Auth interface {
+	Authenticate(req *http.Request)
+}
+
+// BasicAuth logs in with a user and API token, as Jenkins does by default.
+type BasicAuth struct {
+	User  string
+	Token string
+}
+
+func (a BasicAuth) Authenticate(req *http.Request) {
+	req.SetBasicAuth(a.User, a.Token)
+}
+
+// BearerAuth sends a bearer token, such as one from an OAuth or OIDC plugin
+// or a gateway in front of Jenkins.
+type BearerAuth struct {
+	Token string
+}
+
+func (a BearerAuth) Authenticate(req *http.Request) {
+	req.Header.Set("Authorization", "Bearer "+a.Token)
+}
+
+// CookieAuth sends session cookies, like "JSESSIONID.1a2b3c=...", copied
+// from a browser that logged in through single sign-on. They join any
+// cookies already set on the request, as a request has one Cookie header.
+type CookieAuth struct {
+	Cookie string
+}
+
+func (a CookieAuth) Authenticate(req *http.Request) {
+	if cookies := req.Header.Get("Cookie"); cookies != "" {
+		req.Header.Set("Cookie", cookies+"; "+a.Cookie)
+		return
+	}
+	req.Header.Set("Cookie", a.Cookie)
+}
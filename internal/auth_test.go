@@ -0,0 +1,25 @@
+package jenkins
+
+import (
+	"net/http"
+	"slices"
+	"testing"
+)
+
+func TestCookieAuth(t *testing.T) {
+	tests := []struct {
+		name    string
+		headers http.Header
+		want    []string
+	}{
+		{"no cookies", http.Header{}, []string{"JSESSIONID.1a2b3c=abc"}},
+		{"cookies from a header", http.Header{"Cookie": {"a=b"}}, []string{"a=b; JSESSIONID.1a2b3c=abc"}},
+	}
+	for _, tt := range tests {
+		req := &http.Request{Header: tt.headers}
+		CookieAuth{Cookie: "JSESSIONID.1a2b3c=abc"}.Authenticate(req)
+		if got := req.Header.Values("Cookie"); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: Cookie headers are %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package jenkins
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// buildsTree selects the build fields shown in the build history
+const buildsTree = "builds[number,result,building,timestamp,duration,actions[causes[shortDescription]]]"
+
+func jobBuildsUrl(jobUrl string, limit int) string {
+	tree := fmt.Sprintf("%s{0,%d}", buildsTree, limit)
+	return strings.TrimSuffix(jobUrl, "/") + "/api/json?tree=" + url.QueryEscape(tree)
+}
+
+// Build summarizes one build in a job's history.
+type Build struct {
+	Number    int    `json:"number"`
+	Result    string `json:"result"`
+	Building  bool   `json:"building"`
+	Timestamp int64  `json:"timestamp"`
+	Duration  int64  `json:"duration"`
+	Actions   []struct {
+		Causes []struct {
+			ShortDescription string `json:"shortDescription"`
+		} `json:"causes,omitempty"`
+	} `json:"actions"`
+}
+
+// Cause returns the description of what started the build.
+func (b Build) Cause() string {
+	var causes []string
+	for _, action := range b.Actions {
+		for _, cause := range action.Causes {
+			causes = append(causes, cause.ShortDescription)
+		}
+	}
+	return strings.Join(causes, ", ")
+}
+
+// FetchBuilds fetches up to limit of the job's most recent builds, newest
+// first.
+func (c *Client) FetchBuilds(ctx context.Context, limit int) ([]Build, error) {
+	var job struct {
+		Builds []Build `json:"builds"`
+	}
+	err := c.getJson(ctx, jobBuildsUrl(c.server.JobBaseUrl, limit), &job)
+	if err != nil {
+		return nil, err
+	}
+	return job.Builds, nil
+}
@@ -0,0 +1,57 @@
+package jenkins
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"testing"
+)
+
+func TestFetchBuilds(t *testing.T) {
+	fixture, err := os.ReadFile("testdata/builds.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var requested string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.Path + "?" + r.URL.Query().Get("tree")
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(fixture)
+	}))
+	defer server.Close()
+
+	client, err := NewClient(ServerInfo{JobBaseUrl: server.URL + "/job/demo/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	builds, err := client.FetchBuilds(context.Background(), 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "/job/demo/api/json?" + buildsTree + "{0,50}"; requested != want {
+		t.Errorf("requested %q, want %q", requested, want)
+	}
+
+	type summary struct {
+		Number    int
+		Result    string
+		Building  bool
+		Timestamp int64
+		Duration  int64
+		Cause     string
+	}
+	want := []summary{
+		{43, "", true, 1714554000000, 0, "Started by user admin"},
+		{42, "FAILURE", false, 1714550400000, 754321, "Started by an SCM change, Replayed #40"},
+		{41, "SUCCESS", false, 1714546800000, 61000, ""},
+	}
+	got := make([]summary, len(builds))
+	for i, b := range builds {
+		got[i] = summary{b.Number, b.Result, b.Building, b.Timestamp, b.Duration, b.Cause()}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("FetchBuilds = %+v, want %+v", got, want)
+	}
+}
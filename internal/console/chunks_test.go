@@ -0,0 +1,18 @@
+package console
+
+import "strings"
+
+// chunkings returns the ways the tests feed s to a stream parser: whole, split
+// in two at every byte, and one character at a time.
+func chunkings(s string) [][]string {
+	ways := [][]string{{s}}
+	for i := 1; i < len(s); i++ {
+		ways = append(ways, []string{s[:i], s[i:]})
+	}
+	return append(ways, strings.Split(s, ""))
+}
+
+// describe names a way of chunking the input in test failures.
+func describe(chunks []string) string {
+	return strings.Join(chunks, "|")
+}
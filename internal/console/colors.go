@@ -0,0 +1,82 @@
+package console
+
+import (
+	"strings"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// ColorCarrier makes every line of a plain text log self-contained, the
+// same as HtmlConverter does for the annotated log. Builds using the
+// AnsiColor plugin set colors that run on over several lines and chunks of
+// the log, so a line shown on its own would lose its colors, and the line
+// that set them would color everything after it.
+//
+// Colors still in effect at the end of a line are reset there and set again
+// at the start of the next one.
+type ColorCarrier struct {
+	style   ansi.Style
+	partial string
+}
+
+// Carry processes the next chunk of the log. An escape sequence split
+// between chunks is held back until the rest of it arrives.
+func (c *ColorCarrier) Carry(chunk string) string {
+	s := c.partial + chunk
+	c.partial = ""
+	if c.style.IsDefault() && !strings.ContainsRune(s, '\x1b') {
+		return s
+	}
+	var out strings.Builder
+	out.Grow(len(s))
+	lineStart := true
+	for i := 0; i < len(s); {
+		if lineStart {
+			out.WriteString(c.style.Sequence())
+			lineStart = false
+		}
+		switch s[i] {
+		case '\x1b':
+			end, complete := ansi.SequenceEnd(s, i)
+			if !complete {
+				if !strings.Contains(s[i:], "\n") {
+					c.partial = s[i:]
+					return out.String()
+				}
+				// Not a sequence after all, drop the stray ESC
+				i++
+				continue
+			}
+			c.style.Apply(s[i:end])
+			out.WriteString(s[i:end])
+			i = end
+		case '\n':
+			if !c.style.IsDefault() {
+				out.WriteString(ansi.Reset)
+			}
+			out.WriteByte('\n')
+			lineStart = true
+			i++
+		default:
+			j := strings.IndexAny(s[i:], "\x1b\n")
+			if j < 0 {
+				j = len(s) - i
+			}
+			out.WriteString(s[i : i+j])
+			i += j
+		}
+	}
+	return out.String()
+}
+
+// Flush returns what Carry held back at the end of the log, an escape
+// sequence that never finished. Its ESC is dropped like any other stray one.
+func (c *ColorCarrier) Flush() string {
+	var out strings.Builder
+	for c.partial != "" {
+		s := c.partial
+		c.partial = ""
+		out.WriteString(c.Carry(s[1:]))
+	}
+	return out.String()
+}
@@ -0,0 +1,82 @@
+package console
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// styledLines shows each line of s on its own, the way the viewport does,
+// with the style of every character. A style left on at the end of a line
+// shows up as a trailing marker, as it would color the next line.
+func styledLines(s string) []string {
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		var style ansi.Style
+		var b strings.Builder
+		shown := ""
+		for i := 0; i < len(line); {
+			if line[i] == '\x1b' {
+				end, _ := ansi.SequenceEnd(line, i)
+				style.Apply(line[i:end])
+				i = end
+				continue
+			}
+			if seq := style.Sequence(); seq != shown {
+				b.WriteString("<" + seq + ">")
+				shown = seq
+			}
+			b.WriteByte(line[i])
+			i++
+		}
+		if !style.IsDefault() {
+			b.WriteString("<left on>")
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+func TestColorCarrier(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want string
+	}{
+		{"no colors", "line one\nline two\n", "line one\nline two\n"},
+		{"color in a line", "\x1b[31mred\x1b[0m plain\n", "\x1b[31mred\x1b[0m plain\n"},
+		{
+			"color over lines",
+			"\x1b[31mred\nstill red\x1b[0m\nplain\n",
+			"\x1b[31mred\x1b[0m\n\x1b[31mstill red\x1b[0m\nplain\n",
+		},
+		{
+			"attribute turned off",
+			"\x1b[1;31mx\ny\x1b[22m z\n",
+			"\x1b[1;31mx\x1b[0m\n\x1b[1;31my\x1b[22m z\x1b[0m\n",
+		},
+		{"log ends in a sequence", "last line \x1b[", "last line ["},
+	}
+	for _, tt := range tests {
+		for _, chunks := range chunkings(tt.log) {
+			var c ColorCarrier
+			got := ""
+			for _, chunk := range chunks {
+				got += c.Carry(chunk)
+			}
+			got += c.Flush()
+			if len(chunks) == 1 {
+				if got != tt.want {
+					t.Errorf("%s: Carry(%q) = %q, want %q", tt.name, tt.log, got, tt.want)
+				}
+				continue
+			}
+			// A chunk may set the style again, which changes nothing shown
+			if !slices.Equal(styledLines(got), styledLines(tt.want)) {
+				t.Errorf("%s: Carry(%q) = %q, want it to show like %q", tt.name, describe(chunks), got, tt.want)
+			}
+		}
+	}
+}
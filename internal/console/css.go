@@ -0,0 +1,101 @@
+package console
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// cssColors maps the color names most likely to appear in console markup to
+// their RGB values.
+var cssColors = map[string][3]uint8{
+	"black":   {0, 0, 0},
+	"red":     {205, 0, 0},
+	"green":   {0, 205, 0},
+	"yellow":  {205, 205, 0},
+	"blue":    {0, 0, 238},
+	"magenta": {205, 0, 205},
+	"cyan":    {0, 205, 205},
+	"white":   {229, 229, 229},
+	"gray":    {127, 127, 127},
+	"grey":    {127, 127, 127},
+	"orange":  {255, 165, 0},
+}
+
+// cssStyle converts the inline CSS of an element into SGR parameters. Only
+// the properties used by Jenkins plugins such as AnsiColor are supported.
+func cssStyle(css string) string {
+	var params []string
+	for _, declaration := range strings.Split(css, ";") {
+		property, value, ok := strings.Cut(declaration, ":")
+		if !ok {
+			continue
+		}
+		property = strings.ToLower(strings.TrimSpace(property))
+		value = strings.ToLower(strings.TrimSpace(value))
+		switch property {
+		case "color":
+			if rgb, ok := parseColor(value); ok {
+				params = append(params, fmt.Sprintf("38;2;%d;%d;%d", rgb[0], rgb[1], rgb[2]))
+			}
+		case "background-color", "background":
+			if rgb, ok := parseColor(value); ok {
+				params = append(params, fmt.Sprintf("48;2;%d;%d;%d", rgb[0], rgb[1], rgb[2]))
+			}
+		case "font-weight":
+			weight, err := strconv.Atoi(value)
+			if value == "bold" || value == "bolder" || (err == nil && weight >= 600) {
+				params = append(params, "1")
+			}
+		case "font-style":
+			if value == "italic" || value == "oblique" {
+				params = append(params, "3")
+			}
+		case "text-decoration", "text-decoration-line":
+			if strings.Contains(value, "underline") {
+				params = append(params, "4")
+			}
+			if strings.Contains(value, "line-through") {
+				params = append(params, "9")
+			}
+		}
+	}
+	return strings.Join(params, ";")
+}
+
+// parseColor understands #rgb, #rrggbb, rgb(r, g, b) and a few color names.
+func parseColor(value string) ([3]uint8, bool) {
+	if rgb, ok := cssColors[value]; ok {
+		return rgb, true
+	}
+	if strings.HasPrefix(value, "#") {
+		hex := value[1:]
+		if len(hex) == 3 {
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if len(hex) != 6 {
+			return [3]uint8{}, false
+		}
+		n, err := strconv.ParseUint(hex, 16, 32)
+		if err != nil {
+			return [3]uint8{}, false
+		}
+		return [3]uint8{uint8(n >> 16), uint8(n >> 8), uint8(n)}, true
+	}
+	if strings.HasPrefix(value, "rgb(") && strings.HasSuffix(value, ")") {
+		parts := strings.Split(value[4:len(value)-1], ",")
+		if len(parts) != 3 {
+			return [3]uint8{}, false
+		}
+		var rgb [3]uint8
+		for i, p := range parts {
+			n, err := strconv.ParseUint(strings.TrimSpace(p), 10, 8)
+			if err != nil {
+				return [3]uint8{}, false
+			}
+			rgb[i] = uint8(n)
+		}
+		return rgb, true
+	}
+	return [3]uint8{}, false
+}
@@ -0,0 +1,327 @@
+// Package console turns Jenkins console output into text for the terminal.
+package console
+
+import (
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// Section is a block of a Pipeline log, such as the body of a stage, from
+// the line that opens it to the line that closes it.
+type Section struct {
+	Start int
+	// End is -1 while the section is still open
+	End   int
+	Label string
+	Depth int
+}
+
+const (
+	stepMarkerStyle = "2;36"
+	timestampStyle  = "2"
+)
+
+var (
+	tagPattern       = regexp.MustCompile(`^<(/?)([a-zA-Z][a-zA-Z0-9]*)([^>]*?)(/?)>`)
+	attributePattern = regexp.MustCompile(`([a-zA-Z_:][-a-zA-Z0-9_:.]*)\s*=\s*("[^"]*"|'[^']*'|[^\s"'>]+)`)
+	sectionLabel     = regexp.MustCompile(`^\[Pipeline\] \{ \((.*)\)$`)
+)
+
+// element is an open HTML element and the SGR parameters it applies.
+type element struct {
+	name  string
+	style string
+	link  bool
+}
+
+// pipelineMarker is a "[Pipeline] ..." step marker being converted.
+type pipelineMarker struct {
+	line    int
+	nodeId  string
+	startId string
+	label   string
+	text    strings.Builder
+}
+
+// HtmlConverter converts the annotated HTML Jenkins serves from
+// logText/progressiveHtml into text with terminal escape sequences: links
+// become OSC 8 hyperlinks, colors and Pipeline step markers become SGR
+// styles, and Pipeline blocks are recorded as Sections.
+//
+// Every converted line is self-contained: styles and links are closed at the
+// end of each line and opened again on the next one.
+type HtmlConverter struct {
+	base     *url.URL
+	elements []element
+	// link and style are wanted for the next text, written are in effect in
+	// the output so far
+	link         string
+	style        string
+	writtenLink  string
+	writtenStyle string
+	partial      string
+	line         int
+	marker       *pipelineMarker
+	open         map[string]int
+	// Sections lists the Pipeline blocks seen so far, in the order they start
+	Sections []Section
+}
+
+// NewHtmlConverter returns a converter that resolves relative links against
+// baseUrl, usually the URL of the build.
+func NewHtmlConverter(baseUrl string) *HtmlConverter {
+	base, err := url.Parse(baseUrl)
+	if err != nil {
+		base = nil
+	}
+	return &HtmlConverter{base: base, open: make(map[string]int)}
+}
+
+// Convert converts the next chunk of the log. A tag or character reference
+// split between chunks is held back until the rest of it arrives.
+func (c *HtmlConverter) Convert(chunk string) string {
+	s := c.partial + chunk
+	c.partial = ""
+	var out strings.Builder
+	for len(s) > 0 {
+		lt := strings.IndexByte(s, '<')
+		if lt < 0 {
+			keep := len(s) - partialEntity(s)
+			c.text(&out, s[:keep])
+			c.partial = s[keep:]
+			break
+		}
+		c.text(&out, s[:lt])
+		s = s[lt:]
+		gt := strings.IndexByte(s, '>')
+		if gt < 0 {
+			c.partial = s
+			break
+		}
+		match := tagPattern.FindStringSubmatch(s[:gt+1])
+		if match == nil {
+			// Not a tag, just a lone '<'
+			c.text(&out, "<")
+			s = s[1:]
+			continue
+		}
+		s = s[gt+1:]
+		name := strings.ToLower(match[2])
+		switch {
+		case match[1] == "/":
+			c.closeElement(name)
+		case name == "br":
+			c.text(&out, "\n")
+		case match[4] == "/" || voidElements[name]:
+			// Nothing to style
+		default:
+			c.openElement(name, parseAttributes(match[3]))
+		}
+	}
+	return out.String()
+}
+
+// Flush returns what Convert held back at the end of the log, a tag or
+// character reference that never finished, as text.
+func (c *HtmlConverter) Flush() string {
+	var out strings.Builder
+	c.text(&out, c.partial)
+	c.partial = ""
+	return out.String()
+}
+
+// maxEntityLength bounds how much of the end of a chunk is held back as the
+// start of a character reference, like "&amp;" or "&#x27;".
+const maxEntityLength = 32
+
+// partialEntity returns the length of the character reference s ends in the
+// middle of, which may be completed by the next chunk.
+func partialEntity(s string) int {
+	i := strings.LastIndexByte(s, '&')
+	if i < 0 || len(s)-i > maxEntityLength || strings.ContainsAny(s[i:], "; \t\r\n") {
+		return 0
+	}
+	return len(s) - i
+}
+
+var voidElements = map[string]bool{"img": true, "input": true, "hr": true, "wbr": true, "meta": true, "link": true}
+
+func parseAttributes(s string) map[string]string {
+	attributes := make(map[string]string)
+	for _, m := range attributePattern.FindAllStringSubmatch(s, -1) {
+		value := m[2]
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') {
+			value = value[1 : len(value)-1]
+		}
+		attributes[strings.ToLower(m[1])] = html.UnescapeString(value)
+	}
+	return attributes
+}
+
+func (c *HtmlConverter) openElement(name string, attributes map[string]string) {
+	e := element{name: name}
+	classes := strings.Fields(attributes["class"])
+	switch name {
+	case "a":
+		if href, ok := attributes["href"]; ok && c.link == "" {
+			c.link = c.resolve(href)
+			e.link = true
+		}
+	case "b", "strong":
+		e.style = "1"
+	case "i", "em":
+		e.style = "3"
+	case "u":
+		e.style = "4"
+	}
+	for _, class := range classes {
+		switch class {
+		case "pipeline-new-node":
+			e.style = stepMarkerStyle
+			if c.marker == nil {
+				c.marker = &pipelineMarker{
+					line:    c.line,
+					nodeId:  attributes["nodeid"],
+					startId: attributes["startid"],
+					label:   attributes["label"],
+				}
+			}
+		case "timestamp":
+			e.style = timestampStyle
+		}
+	}
+	if css, ok := attributes["style"]; ok {
+		e.style = joinStyles(e.style, cssStyle(css))
+	}
+	c.elements = append(c.elements, e)
+	c.updateStyle()
+}
+
+func (c *HtmlConverter) closeElement(name string) {
+	// Close the innermost matching element, and any left open inside it
+	for i := len(c.elements) - 1; i >= 0; i-- {
+		if c.elements[i].name != name {
+			continue
+		}
+		for _, e := range c.elements[i:] {
+			if e.link {
+				c.link = ""
+			}
+			if e.style == stepMarkerStyle && c.marker != nil {
+				c.endMarker()
+			}
+		}
+		c.elements = c.elements[:i]
+		c.updateStyle()
+		return
+	}
+}
+
+// text writes text content, closing styles and links at every line break.
+func (c *HtmlConverter) text(out *strings.Builder, s string) {
+	s = html.UnescapeString(s)
+	for len(s) > 0 {
+		nl := strings.IndexByte(s, '\n')
+		if nl < 0 {
+			nl = len(s)
+		}
+		if nl > 0 {
+			c.flushStyle(out)
+			out.WriteString(s[:nl])
+			if c.marker != nil {
+				c.marker.text.WriteString(s[:nl])
+			}
+		}
+		if nl == len(s) {
+			return
+		}
+		if c.writtenLink != "" {
+			out.WriteString(ansi.LinkEnd)
+			c.writtenLink = ""
+		}
+		if c.writtenStyle != "" {
+			out.WriteString(ansi.Reset)
+			c.writtenStyle = ""
+		}
+		out.WriteByte('\n')
+		c.line++
+		s = s[nl+1:]
+	}
+}
+
+// flushStyle writes the escape sequences needed to switch from the style and
+// link in effect to the wanted ones.
+func (c *HtmlConverter) flushStyle(out *strings.Builder) {
+	if c.link != c.writtenLink {
+		if c.writtenLink != "" {
+			out.WriteString(ansi.LinkEnd)
+		}
+		if c.link != "" {
+			out.WriteString(ansi.LinkStart(c.link))
+		}
+		c.writtenLink = c.link
+	}
+	if c.style != c.writtenStyle {
+		out.WriteString(ansi.Reset)
+		if c.style != "" {
+			out.WriteString("\x1b[" + c.style + "m")
+		}
+		c.writtenStyle = c.style
+	}
+}
+
+// updateStyle combines the styles of every open element.
+func (c *HtmlConverter) updateStyle() {
+	c.style = ""
+	for _, e := range c.elements {
+		c.style = joinStyles(c.style, e.style)
+	}
+}
+
+func joinStyles(a, b string) string {
+	if a == "" {
+		return b
+	}
+	if b == "" {
+		return a
+	}
+	return a + ";" + b
+}
+
+// resolve makes a link absolute, so it can be opened from the terminal.
+func (c *HtmlConverter) resolve(href string) string {
+	u, err := url.Parse(href)
+	if err != nil || c.base == nil {
+		return href
+	}
+	return c.base.ResolveReference(u).String()
+}
+
+// endMarker records the Pipeline block a step marker opens or closes.
+func (c *HtmlConverter) endMarker() {
+	m := c.marker
+	c.marker = nil
+	text := strings.TrimSpace(m.text.String())
+	if m.startId != "" {
+		if i, ok := c.open[m.startId]; ok {
+			c.Sections[i].End = m.line
+			delete(c.open, m.startId)
+		}
+		return
+	}
+	if !strings.HasPrefix(text, "[Pipeline] {") || m.nodeId == "" {
+		return
+	}
+	label := m.label
+	if label == "" {
+		if match := sectionLabel.FindStringSubmatch(text); match != nil {
+			label = match[1]
+		}
+	}
+	c.open[m.nodeId] = len(c.Sections)
+	c.Sections = append(c.Sections, Section{Start: m.line, End: -1, Label: label, Depth: len(c.open) - 1})
+}
@@ -0,0 +1,79 @@
+package console
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestHtmlConverter(t *testing.T) {
+	const stepMarker = "\x1b[0m\x1b[2;36m"
+	tests := []struct {
+		name     string
+		log      string
+		want     string
+		sections []Section
+	}{
+		{"plain", "line one\nline two\n", "line one\nline two\n", nil},
+		{"character references", "a &lt;b&gt; &amp; c &#39;d&#x27;\n", "a <b> & c 'd'\n", nil},
+		{"ampersand", "AT&T\n", "AT&T\n", nil},
+		{"log ends in a reference", "end &amp", "end &", nil},
+		{
+			"link",
+			"<a href='/user/admin' class='jenkins-table__link'>admin</a>\n",
+			"\x1b]8;;http://j/user/admin\x1b\\admin\x1b]8;;\x1b\\\n",
+			nil,
+		},
+		{
+			"style over lines",
+			"<span style=\"color: #CD0000;\">error\ntwo</span> ok\n",
+			"\x1b[0m\x1b[38;2;205;0;0merror\x1b[0m\n\x1b[0m\x1b[38;2;205;0;0mtwo\x1b[0m ok\n",
+			nil,
+		},
+		{"bold", "<b>Finished</b>: SUCCESS\n", "\x1b[0m\x1b[1mFinished\x1b[0m: SUCCESS\n", nil},
+		{"lone <", "1 < 2 <i>x</i><br>y\n", "1 < 2 \x1b[0m\x1b[3mx\x1b[0m\ny\n", nil},
+		{
+			"Pipeline section",
+			"<span class=\"pipeline-new-node\" nodeId=\"3\" enclosingId=\"2\">[Pipeline] { (Build &amp; test)\n</span>" +
+				"make\n" +
+				"<span class=\"pipeline-new-node\" nodeId=\"4\" startId=\"3\" enclosingId=\"2\">[Pipeline] }\n</span>",
+			stepMarker + "[Pipeline] { (Build & test)\x1b[0m\nmake\n" + stepMarker + "[Pipeline] }\x1b[0m\n",
+			[]Section{{Start: 0, End: 2, Label: "Build & test"}},
+		},
+	}
+	for _, tt := range tests {
+		for _, chunks := range chunkings(tt.log) {
+			c := NewHtmlConverter("http://j/job/demo/4/")
+			got := ""
+			for _, chunk := range chunks {
+				got += c.Convert(chunk)
+			}
+			got += c.Flush()
+			if got != tt.want {
+				t.Errorf("%s: Convert(%q) = %q, want %q", tt.name, describe(chunks), got, tt.want)
+			}
+			if !slices.Equal(c.Sections, tt.sections) {
+				t.Errorf("%s: Convert(%q) found sections %+v, want %+v", tt.name, describe(chunks), c.Sections, tt.sections)
+			}
+		}
+	}
+}
+
+func TestCssStyle(t *testing.T) {
+	tests := []struct {
+		css  string
+		want string
+	}{
+		{"color: #CD0000;", "38;2;205;0;0"},
+		{"color: #f00", "38;2;255;0;0"},
+		{"background-color: rgb(1, 2, 3)", "48;2;1;2;3"},
+		{"color: red; font-weight: bold", "38;2;205;0;0;1"},
+		{"font-weight: 700; font-style: italic", "1;3"},
+		{"text-decoration: underline line-through", "4;9"},
+		{"display: none; color: nonsense", ""},
+	}
+	for _, tt := range tests {
+		if got := cssStyle(tt.css); got != tt.want {
+			t.Errorf("cssStyle(%q) = %q, want %q", tt.css, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,75 @@
+package console
+
+import "strings"
+
+// Jenkins hides console notes in the plain text log between these markers.
+// The payload is a base64 encoded, serialized Java object that only Jenkins
+// itself can make use of.
+const (
+	notePreamble  = "\x1b[8mha:"
+	notePostamble = "\x1b[0m"
+)
+
+// NoteStripper removes the console notes Jenkins embeds in the plain text
+// log from logText/progressiveText, such as the ones that mark Pipeline steps,
+// so the log reads the same as in the Jenkins UI.
+type NoteStripper struct {
+	partial string
+	inNote  bool
+}
+
+// Strip removes the notes from the next chunk of the log. A note split
+// between chunks is removed once the rest of it arrives.
+func (n *NoteStripper) Strip(chunk string) string {
+	s := n.partial + chunk
+	n.partial = ""
+	if !n.inNote && !strings.Contains(s, "\x1b") {
+		return s
+	}
+	var out strings.Builder
+	for len(s) > 0 {
+		if n.inNote {
+			i := strings.Index(s, notePostamble)
+			if i < 0 {
+				n.partial = s[len(s)-partialPrefix(s, notePostamble):]
+				break
+			}
+			s = s[i+len(notePostamble):]
+			n.inNote = false
+			continue
+		}
+		i := strings.Index(s, notePreamble)
+		if i < 0 {
+			keep := len(s) - partialPrefix(s, notePreamble)
+			out.WriteString(s[:keep])
+			n.partial = s[keep:]
+			break
+		}
+		out.WriteString(s[:i])
+		s = s[i+len(notePreamble):]
+		n.inNote = true
+	}
+	return out.String()
+}
+
+// Flush returns what Strip held back at the end of the log, which turned
+// out not to be the start of a note. A note the log ends in is dropped.
+func (n *NoteStripper) Flush() string {
+	s := n.partial
+	if n.inNote {
+		s = ""
+	}
+	n.partial, n.inNote = "", false
+	return s
+}
+
+// partialPrefix returns the length of the longest end of s that marker
+// starts with, which may be completed by the next chunk.
+func partialPrefix(s, marker string) int {
+	for l := min(len(s), len(marker)-1); l > 0; l-- {
+		if strings.HasSuffix(s, marker[:l]) {
+			return l
+		}
+	}
+	return 0
+}
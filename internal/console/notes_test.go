@@ -0,0 +1,32 @@
+package console
+
+import "testing"
+
+func TestNoteStripper(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want string
+	}{
+		{"no notes", "line one\nline two\n", "line one\nline two\n"},
+		{"note", "\x1b[8mha:AAAAlw==\x1b[0m[Pipeline] sh\n", "[Pipeline] sh\n"},
+		{"notes in a line", "a\x1b[8mha:AA==\x1b[0mb\x1b[8mha:BB==\x1b[0mc\n", "abc\n"},
+		{"colors", "\x1b[31mred\x1b[0m\n", "\x1b[31mred\x1b[0m\n"},
+		{"note next to colors", "\x1b[8mha:AA==\x1b[0m\x1b[1mbold\x1b[0m\n", "\x1b[1mbold\x1b[0m\n"},
+		{"ends in part of a marker", "done\x1b[8m", "done\x1b[8m"},
+		{"ends in a note", "done\n\x1b[8mha:AAAA", "done\n"},
+	}
+	for _, tt := range tests {
+		for _, chunks := range chunkings(tt.log) {
+			var n NoteStripper
+			got := ""
+			for _, chunk := range chunks {
+				got += n.Strip(chunk)
+			}
+			got += n.Flush()
+			if got != tt.want {
+				t.Errorf("%s: Strip(%q) = %q, want %q", tt.name, describe(chunks), got, tt.want)
+			}
+		}
+	}
+}
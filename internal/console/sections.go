@@ -0,0 +1,71 @@
+package console
+
+import (
+	"strings"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+const (
+	sectionOpen  = "[Pipeline] {"
+	sectionClose = "[Pipeline] }"
+)
+
+// SectionParser finds the Pipeline blocks in plain text console output, from
+// the "[Pipeline] {" step marker that opens each one to the "[Pipeline] }"
+// that closes it.
+type SectionParser struct {
+	lines lineReader
+	// open lists the sections not closed yet, innermost last
+	open []int
+	// Sections lists the Pipeline blocks seen so far, in the order they
+	// start. With their Depth they form a tree.
+	Sections []Section
+}
+
+// Parse records the sections in the next chunk of output. A line split
+// across chunks is parsed once the rest of it arrives.
+func (p *SectionParser) Parse(chunk string) {
+	p.lines.read(chunk, p.parseLine)
+}
+
+func (p *SectionParser) parseLine(line int, text string) {
+	text = strings.TrimSpace(text)
+	switch {
+	case text == sectionClose:
+		if len(p.open) > 0 {
+			p.Sections[p.open[len(p.open)-1]].End = line
+			p.open = p.open[:len(p.open)-1]
+		}
+	case strings.HasPrefix(text, sectionOpen):
+		label := ""
+		if match := sectionLabel.FindStringSubmatch(text); match != nil {
+			label = match[1]
+		}
+		p.open = append(p.open, len(p.Sections))
+		p.Sections = append(p.Sections, Section{Start: line, End: -1, Label: label, Depth: len(p.open) - 1})
+	}
+}
+
+// lineReader splits chunks of output into lines, holding back a line split
+// across chunks until the rest of it arrives.
+type lineReader struct {
+	partial string
+	line    int
+}
+
+// read calls f with the number and text of each line completed by chunk,
+// with escape sequences removed.
+func (r *lineReader) read(chunk string, f func(line int, text string)) {
+	text := r.partial + chunk
+	for {
+		nl := strings.IndexByte(text, '\n')
+		if nl < 0 {
+			break
+		}
+		f(r.line, ansi.Strip(strings.TrimSuffix(text[:nl], "\r")))
+		text = text[nl+1:]
+		r.line++
+	}
+	r.partial = text
+}
@@ -0,0 +1,49 @@
+package console
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestSectionParser(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []Section
+	}{
+		{"no sections", "a\nb\n", nil},
+		{
+			"stages",
+			"[Pipeline] stage\n[Pipeline] { (Build)\nmake\n[Pipeline] }\n[Pipeline] stage\n[Pipeline] { (Test)\n[Pipeline] }\n",
+			[]Section{{Start: 1, End: 3, Label: "Build"}, {Start: 5, End: 6, Label: "Test"}},
+		},
+		{
+			"nested",
+			"[Pipeline] {\n[Pipeline] { (Inner)\nx\n[Pipeline] }\n[Pipeline] }\n",
+			[]Section{{Start: 0, End: 4}, {Start: 1, End: 3, Label: "Inner", Depth: 1}},
+		},
+		{
+			"open",
+			"[Pipeline] { (Deploy)\ndeploying\n",
+			[]Section{{Start: 0, End: -1, Label: "Deploy"}},
+		},
+		{
+			"colors and CRLF",
+			"\x1b[36m[Pipeline] { (Build)\x1b[0m\r\n\x1b[36m[Pipeline] }\x1b[0m\r\n",
+			[]Section{{Start: 0, End: 1, Label: "Build"}},
+		},
+		{"unmatched close", "[Pipeline] }\n", nil},
+		{"line not finished", "[Pipeline] { (Build)", nil},
+	}
+	for _, tt := range tests {
+		for _, chunks := range chunkings(tt.log) {
+			var p SectionParser
+			for _, chunk := range chunks {
+				p.Parse(chunk)
+			}
+			if !slices.Equal(p.Sections, tt.want) {
+				t.Errorf("%s: Parse(%q) found %+v, want %+v", tt.name, describe(chunks), p.Sections, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package console
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Trace is part of a stack trace: the line describing an exception, panic or
+// traceback, and the frames after it up to End. A chained Java exception has
+// a Trace for each "Caused by:", so the causes stay visible when the frames
+// are hidden.
+type Trace struct {
+	Start int
+	End   int
+}
+
+// traceKind is the language of the stack trace being read.
+type traceKind int
+
+const (
+	noTrace traceKind = iota
+	javaTrace
+	pythonTrace
+	goTrace
+)
+
+var (
+	// Java and JavaScript frames, "at com.example.Foo.bar(Foo.java:12)" and
+	// "at bar (/src/foo.js:12:3)"
+	javaFrame   = regexp.MustCompile(`^\s+at (new |async )*\S+( ?\(|:\d+)`)
+	javaOmitted = regexp.MustCompile(`^\s+\.\.\. \d+ (more|common frames omitted)`)
+	javaCause   = regexp.MustCompile(`^\s*(Caused by|Suppressed): `)
+	pythonStart = "Traceback (most recent call last):"
+	goStart     = regexp.MustCompile(`^(panic|fatal error): `)
+	// Go goroutine headers, "created by" lines, signals, functions and the
+	// tab indented files below them
+	goFrame = regexp.MustCompile(`^(goroutine \d+ \[.*\]:|created by |\[signal |\t|[\w.*/()\[\]$~-]+\(.*\)$)`)
+)
+
+// TraceFinder finds the stack traces in console output: Java and JavaScript
+// exceptions, Python tracebacks and Go panics.
+type TraceFinder struct {
+	lines    lineReader
+	kind     traceKind
+	previous string
+	// Traces lists the stack traces seen so far, in order. The last one may
+	// still grow as more frames arrive.
+	Traces []Trace
+}
+
+// Parse records the stack traces in the next chunk of output. A line split
+// across chunks is parsed once the rest of it arrives.
+func (f *TraceFinder) Parse(chunk string) {
+	f.lines.read(chunk, f.parseLine)
+}
+
+func (f *TraceFinder) parseLine(line int, text string) {
+	defer func() { f.previous = text }()
+	if f.kind != noTrace && f.continues(line, text) {
+		return
+	}
+	f.kind = noTrace
+	switch {
+	case strings.TrimSpace(text) == pythonStart:
+		f.start(pythonTrace, line)
+	case goStart.MatchString(text):
+		f.start(goTrace, line)
+	case javaFrame.MatchString(text) && strings.TrimSpace(f.previous) != "":
+		// The exception is only recognizable by the frames after it
+		f.start(javaTrace, line-1)
+		f.extend(line)
+	}
+}
+
+// continues reports whether line carries on the stack trace being read,
+// extending it.
+func (f *TraceFinder) continues(line int, text string) bool {
+	switch f.kind {
+	case javaTrace:
+		if javaCause.MatchString(text) {
+			f.start(javaTrace, line)
+			return true
+		}
+		if javaFrame.MatchString(text) || javaOmitted.MatchString(text) {
+			f.extend(line)
+			return true
+		}
+	case pythonTrace:
+		// Frames and source lines are indented, the exception that ends the
+		// traceback isn't
+		if strings.HasPrefix(text, " ") {
+			f.extend(line)
+			return true
+		}
+	case goTrace:
+		if strings.TrimSpace(text) == "" {
+			return true
+		}
+		if goFrame.MatchString(text) {
+			f.extend(line)
+			return true
+		}
+	}
+	return false
+}
+
+func (f *TraceFinder) start(kind traceKind, line int) {
+	f.kind = kind
+	f.Traces = append(f.Traces, Trace{Start: line, End: line})
+}
+
+func (f *TraceFinder) extend(line int) {
+	f.Traces[len(f.Traces)-1].End = line
+}
@@ -0,0 +1,60 @@
+package console
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestTraceFinder(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []Trace
+	}{
+		{"no traces", "compiling\nat noon\n", nil},
+		{
+			"java",
+			"Building\n" +
+				"java.lang.IllegalStateException: boom\n" +
+				"\tat com.example.Foo.bar(Foo.java:12)\n" +
+				"\tat com.example.Main.main(Main.java:3)\n" +
+				"Caused by: java.io.IOException: disk\n" +
+				"\tat com.example.Disk.read(Disk.java:7)\n" +
+				"\t... 2 more\n" +
+				"Done\n",
+			[]Trace{{Start: 1, End: 3}, {Start: 4, End: 6}},
+		},
+		{
+			"javascript",
+			"TypeError: x is undefined\n    at bar (/src/foo.js:12:3)\n    at async main (/src/foo.js:20:1)\n",
+			[]Trace{{Start: 0, End: 2}},
+		},
+		{
+			"python",
+			"Traceback (most recent call last):\n  File \"a.py\", line 1, in <module>\n    main()\nValueError: bad\n",
+			[]Trace{{Start: 0, End: 2}},
+		},
+		{
+			"go",
+			"panic: oops\n\ngoroutine 1 [running]:\nmain.main()\n\t/src/main.go:5 +0x1d\nexit status 2\n",
+			[]Trace{{Start: 0, End: 4}},
+		},
+		{"frame without an exception", "\n\tat com.example.Foo.bar(Foo.java:12)\n", nil},
+		{
+			"colored",
+			"\x1b[31mjava.lang.RuntimeException: x\x1b[0m\r\n\x1b[31m\tat Foo.bar(Foo.java:1)\x1b[0m\r\n",
+			[]Trace{{Start: 0, End: 1}},
+		},
+	}
+	for _, tt := range tests {
+		for _, chunks := range chunkings(tt.log) {
+			var f TraceFinder
+			for _, chunk := range chunks {
+				f.Parse(chunk)
+			}
+			if !slices.Equal(f.Traces, tt.want) {
+				t.Errorf("%s: Parse(%q) found %+v, want %+v", tt.name, describe(chunks), f.Traces, tt.want)
+			}
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package jenkins
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+)
+
+// Error kinds returned by the Jenkins client. Use errors.Is to check which
+// kind of failure a request ran into.
+var (
+	ErrUnauthorized      = errors.New("unauthorized, check user and token")
+	ErrForbidden         = errors.New("forbidden, user lacks permission")
+	ErrNotFound          = errors.New("not found, check the job URL")
+	ErrServer            = errors.New("jenkins server error")
+	ErrTimeout           = errors.New("request timed out")
+	ErrConnection        = errors.New("connection failed")
+	ErrMalformedResponse = errors.New("malformed response")
+)
+
+// RequestError describes a failed request to Jenkins.
+type RequestError struct {
+	Kind       error
+	Method     string
+	Url        string
+	StatusCode int
+	Err        error
+}
+
+func (e *RequestError) Error() string {
+	msg := e.Kind.Error()
+	if e.StatusCode != 0 {
+		msg += fmt.Sprintf(" (status %d)", e.StatusCode)
+	}
+	msg += fmt.Sprintf(": %s %s", e.Method, e.Url)
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *RequestError) Unwrap() error {
+	return e.Err
+}
+
+func (e *RequestError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func transportError(req *http.Request, err error) error {
+	// The url.Error wrapper repeats the method and URL we already report
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		err = urlErr.Err
+	}
+	kind := ErrConnection
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		kind = ErrTimeout
+	}
+	return &RequestError{Kind: kind, Method: req.Method, Url: req.URL.String(), Err: err}
+}
+
+func statusError(resp *http.Response) error {
+	var kind error
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized:
+		kind = ErrUnauthorized
+	case resp.StatusCode == http.StatusForbidden:
+		kind = ErrForbidden
+	case resp.StatusCode == http.StatusNotFound:
+		kind = ErrNotFound
+	case resp.StatusCode >= 500:
+		kind = ErrServer
+	default:
+		kind = ErrMalformedResponse
+	}
+	return &RequestError{
+		Kind:       kind,
+		Method:     resp.Request.Method,
+		Url:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+	}
+}
+
+func malformedError(resp *http.Response, err error) error {
+	return &RequestError{
+		Kind:       ErrMalformedResponse,
+		Method:     resp.Request.Method,
+		Url:        resp.Request.URL.String(),
+		StatusCode: resp.StatusCode,
+		Err:        err,
+	}
+}
@@ -3,53 +3,155 @@ package jenkins
 import (
 	"bufio"
 	"bytes"
-	"encoding/base64"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
-	"log"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 	"time"
 )
 
-func basicAuth(username, password string) string {
-	auth := username + ":" + password
-	return base64.StdEncoding.EncodeToString([]byte(auth))
+// LastBuild is the permalink to the most recent build of a job
+const LastBuild = "lastBuild"
+
+// Permalinks are the build names Jenkins accepts in place of a build number
+var Permalinks = []string{
+	LastBuild,
+	"lastCompletedBuild",
+	"lastFailedBuild",
+	"lastStableBuild",
+	"lastSuccessfulBuild",
+	"lastUnstableBuild",
+	"lastUnsuccessfulBuild",
+}
+
+// ParseBuild checks that build is either a build number or one of the
+// Permalinks, returning it in the form used in URLs.
+func ParseBuild(build string) (string, error) {
+	if n, err := strconv.Atoi(build); err == nil && n > 0 {
+		return strconv.Itoa(n), nil
+	}
+	for _, p := range Permalinks {
+		if strings.EqualFold(build, p) {
+			return p, nil
+		}
+	}
+	return "", fmt.Errorf("invalid build %q: expected a build number or one of %s",
+		build, strings.Join(Permalinks, ", "))
+}
+
+func buildUrl(url string, build string) string {
+	return strings.TrimSuffix(url, "/") + "/" + build
+}
+
+func jobStatusUrl(url string, build string) string {
+	return buildUrl(url, build) + "/api/json"
 }
 
-func jobStatusUrl(url string) string {
-	return url + "/lastBuild/api/json"
+func jobLogUrl(url string, build string, start int64) string {
+	return fmt.Sprintf("%s/logText/progressiveText?start=%d", buildUrl(url, build), start)
 }
 
-func jobLogUrl(url string, start int64) string {
-	return fmt.Sprintf("%s/lastBuild/logText/progressiveText?start=%d", url, start)
+func jobHtmlLogUrl(url string, build string, start int64) string {
+	return fmt.Sprintf("%s/logText/progressiveHtml?start=%d", buildUrl(url, build), start)
 }
 
-func FetchJobStatus(server ServerInfo) (*JobStatus, error) {
+// Client fetches job status and logs from a Jenkins server. A Client holds a
+// pooled transport, so create one per ServerInfo and reuse it. It is safe for
+// concurrent use.
+type Client struct {
+	server     ServerInfo
+	httpClient *http.Client
+}
+
+// NewClient returns a Client for the job described by server. It fails if
+// the files named in the server's TLS options can't be loaded, or the proxy
+// isn't a valid URL.
+func NewClient(server ServerInfo) (*Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := server.TLS.config()
+	if err != nil {
+		return nil, err
+	}
+	if tlsConfig != nil {
+		transport.TLSClientConfig = tlsConfig
+	}
+	switch server.Proxy {
+	case "":
+	case NoProxy:
+		transport.Proxy = nil
+	default:
+		proxy, err := url.Parse(server.Proxy)
+		if err != nil || proxy.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", server.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	return &Client{
+		server:     server,
+		httpClient: &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}, nil
+}
+
+// FetchJobStatus fetches the status of build, which is a build number or one
+// of the Permalinks.
+func (c *Client) FetchJobStatus(ctx context.Context, build string) (*JobStatus, error) {
 	jobStatus := new(JobStatus)
-	err := getJson(server, jobStatus)
+	err := c.getJson(ctx, jobStatusUrl(c.server.JobBaseUrl, build), jobStatus)
 	if err != nil {
 		return nil, err
 	}
 	return jobStatus, nil
 }
 
-func fetchLogChunk(server ServerInfo, position int64) *http.Response {
-	logUrl := jobLogUrl(server.JobBaseUrl, position)
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", logUrl, nil)
-	req.Header.Add("Authorization", "Basic "+basicAuth(server.User, server.Token))
-	resp, err := client.Do(req)
+// BuildUrl returns the URL of build, which is a build number or one of the
+// Permalinks.
+func (c *Client) BuildUrl(build string) string {
+	return buildUrl(c.server.JobBaseUrl, build) + "/"
+}
+
+// newRequest creates a GET request with the server's headers and
+// credentials.
+func (c *Client) newRequest(ctx context.Context, url string) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range c.server.Headers {
+		req.Header[name] = append([]string(nil), values...)
+	}
+	if c.server.Auth != nil {
+		c.server.Auth.Authenticate(req)
+	}
+	return req, nil
+}
+
+// get performs a GET request, returning the response if Jenkins answered with
+// status 200. The caller must close the response body.
+func (c *Client) get(ctx context.Context, url string) (*http.Response, error) {
+	req, err := c.newRequest(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+	return c.do(req)
+}
+
+// do sends a request, returning the response if Jenkins answered with status
+// 200. The caller must close the response body.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	resp, err := c.httpClient.Do(req)
 
 	if err != nil {
-		log.Fatal(err)
+		return nil, transportError(req, err)
 	}
 	if resp.StatusCode != 200 {
-		fmt.Printf("%s %s\n", req.Method, req.URL)
-		log.Fatalf("Jenkins responded with status %d, expecting 200. Bad credentials?", resp.StatusCode)
+		_ = resp.Body.Close()
+		return nil, statusError(resp)
 	}
-	return resp
+	return resp, nil
 }
 
 type LogChunk struct {
@@ -58,11 +160,44 @@ type LogChunk struct {
 	Start       int64
 	MoreData    bool
 	NewPosition int64
+	// Annotator is the console annotation state to pass to the next
+	// FetchHtmlLog call
+	Annotator string
 }
 
-func FetchLog(server ServerInfo, start int64) LogChunk {
-	resp := fetchLogChunk(server, start)
-	buf := processLogChunk(resp)
+// FetchLog fetches the console log of build starting at byte offset start.
+func (c *Client) FetchLog(ctx context.Context, build string, start int64) (LogChunk, error) {
+	req, err := c.newRequest(ctx, jobLogUrl(c.server.JobBaseUrl, build, start))
+	if err != nil {
+		return LogChunk{}, err
+	}
+	return c.fetchLog(req, start)
+}
+
+// FetchHtmlLog fetches the console log of build as annotated HTML, starting
+// at byte offset start of the plain text log. Pass the Annotator of the
+// previous chunk, or "" for the first one, so annotations spanning chunks
+// are rendered correctly.
+func (c *Client) FetchHtmlLog(ctx context.Context, build string, start int64, annotator string) (LogChunk, error) {
+	req, err := c.newRequest(ctx, jobHtmlLogUrl(c.server.JobBaseUrl, build, start))
+	if err != nil {
+		return LogChunk{}, err
+	}
+	if annotator != "" {
+		req.Header.Set("X-ConsoleAnnotator", annotator)
+	}
+	return c.fetchLog(req, start)
+}
+
+func (c *Client) fetchLog(req *http.Request, start int64) (LogChunk, error) {
+	resp, err := c.do(req)
+	if err != nil {
+		return LogChunk{}, err
+	}
+	buf, err := processLogChunk(resp)
+	if err != nil {
+		return LogChunk{}, err
+	}
 
 	moreData, err := strconv.ParseBool(resp.Header.Get("X-More-Data"))
 	if err != nil {
@@ -71,7 +206,7 @@ func FetchLog(server ServerInfo, start int64) LogChunk {
 
 	newPosition, err := strconv.ParseInt(resp.Header.Get("X-Text-Size"), 10, 64)
 	if err != nil {
-		log.Fatal(err)
+		return LogChunk{}, malformedError(resp, fmt.Errorf("invalid X-Text-Size header: %w", err))
 	}
 	return LogChunk{
 		Body:        buf,
@@ -79,15 +214,13 @@ func FetchLog(server ServerInfo, start int64) LogChunk {
 		Start:       start,
 		MoreData:    moreData,
 		NewPosition: newPosition,
-	}
+		Annotator:   resp.Header.Get("X-ConsoleAnnotator"),
+	}, nil
 }
 
-func processLogChunk(resp *http.Response) string {
+func processLogChunk(resp *http.Response) (string, error) {
 	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
+		_ = Body.Close()
 	}(resp.Body)
 
 	scanner := bufio.NewScanner(resp.Body)
@@ -96,36 +229,42 @@ func processLogChunk(resp *http.Response) string {
 	for scanner.Scan() {
 		buf.WriteString(scanner.Text())
 	}
-	return buf.String()
+	if err := scanner.Err(); err != nil {
+		return "", transportError(resp.Request, err)
+	}
+	return buf.String(), nil
 }
 
-func getJson(server ServerInfo, target interface{}) error {
-	client := &http.Client{Timeout: 10 * time.Second}
-	req, _ := http.NewRequest("GET", jobStatusUrl(server.JobBaseUrl), nil)
-	req.Header.Add("Authorization", "Basic "+basicAuth(server.User, server.Token))
-	resp, err := client.Do(req)
-
+func (c *Client) getJson(ctx context.Context, url string, target interface{}) error {
+	resp, err := c.get(ctx, url)
 	if err != nil {
 		return err
 	}
 	defer func(Body io.ReadCloser) {
-		err := Body.Close()
-		if err != nil {
-			log.Fatal(err)
-		}
+		_ = Body.Close()
 	}(resp.Body)
 
-	if resp.StatusCode != 200 {
-		fmt.Printf("%s %s\n", req.Method, req.URL)
-		log.Fatalf("Jenkins responded with status %d, expecting 200. Bad credentials?", resp.StatusCode)
+	if err := json.NewDecoder(resp.Body).Decode(target); err != nil {
+		return malformedError(resp, err)
 	}
-	return json.NewDecoder(resp.Body).Decode(target)
+	return nil
 }
 
+// NoProxy as the Proxy of a ServerInfo connects directly, ignoring the
+// proxy environment variables.
+const NoProxy = "none"
+
 type ServerInfo struct {
 	JobBaseUrl string
-	User       string
-	Token      string
+	// Auth logs in to Jenkins, or is nil to access it anonymously
+	Auth Auth
+	// Headers are sent with every request, for gateways in front of Jenkins
+	Headers http.Header
+	// Proxy is the URL of the proxy to connect through, NoProxy, or empty to
+	// use the proxy from the HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	// environment variables
+	Proxy string
+	TLS   TLSOptions
 }
 
 type JobStatus struct {
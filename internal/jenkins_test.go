@@ -0,0 +1,28 @@
+package jenkins
+
+import "testing"
+
+func TestParseBuild(t *testing.T) {
+	tests := []struct {
+		build   string
+		want    string
+		wantErr bool
+	}{
+		{"42", "42", false},
+		{"007", "7", false},
+		{"lastBuild", "lastBuild", false},
+		{"lastfailedbuild", "lastFailedBuild", false},
+		{"LASTSUCCESSFULBUILD", "lastSuccessfulBuild", false},
+		{"0", "", true},
+		{"-1", "", true},
+		{"", "", true},
+		{"latest", "", true},
+		{"42/console", "", true},
+	}
+	for _, tt := range tests {
+		got, err := ParseBuild(tt.build)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("ParseBuild(%q) = %q, %v, want %q, error %v", tt.build, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
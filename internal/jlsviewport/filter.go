@@ -0,0 +1,120 @@
+package jlsviewport
+
+import (
+	"regexp"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// Filter limits the lines shown to the ones matching its patterns, and the
+// lines around them.
+type Filter struct {
+	// Include shows only lines that match all of these patterns
+	Include []*regexp.Regexp
+	// Exclude hides lines that match any of these patterns, even when they
+	// are next to an included line
+	Exclude []*regexp.Regexp
+	// Context is the number of lines to show before and after each included
+	// line
+	Context int
+}
+
+// Active reports whether the filter hides any lines.
+func (f Filter) Active() bool {
+	return len(f.Include) > 0 || len(f.Exclude) > 0
+}
+
+// SetFilter shows only the lines that pass f, ignoring folds while it is
+// active. New content is filtered as it's added.
+func (m *Model) SetFilter(f Filter) {
+	m.filter = f
+	m.included = nil
+	m.excluded = nil
+	m.updateFilter(0)
+	m.refilter()
+}
+
+// refilter updates the rows after the filter changed, staying at the bottom
+// if the viewport was there.
+func (m *Model) refilter() {
+	atBottom := m.AtBottom()
+	m.updateRows()
+	if atBottom || m.PastBottom() {
+		m.GotoBottom()
+	}
+}
+
+// Filter returns the current filter.
+func (m Model) Filter() Filter {
+	return m.filter
+}
+
+// ShownLineCount returns the number of lines that pass the filter.
+func (m Model) ShownLineCount() int {
+	if !m.filter.Active() {
+		return len(m.lines)
+	}
+	return len(m.rows)
+}
+
+// updateFilter matches the lines from first on, which have changed since
+// they were last matched, against the filter.
+func (m *Model) updateFilter(first int) {
+	if !m.filter.Active() {
+		return
+	}
+	first = min(first, len(m.included))
+	m.included = m.included[:first]
+	m.excluded = m.excluded[:first]
+	for _, line := range m.lines[first:] {
+		text := ansi.Strip(line)
+		included := true
+		for _, pattern := range m.filter.Include {
+			if !pattern.MatchString(text) {
+				included = false
+				break
+			}
+		}
+		excluded := false
+		for _, pattern := range m.filter.Exclude {
+			if pattern.MatchString(text) {
+				excluded = true
+				break
+			}
+		}
+		m.included = append(m.included, included && !excluded)
+		m.excluded = append(m.excluded, excluded)
+	}
+}
+
+// filteredLines returns the lines that pass the filter, in order.
+func (m Model) filteredLines() []int {
+	if len(m.filter.Include) == 0 || m.filter.Context == 0 {
+		lines := make([]int, 0, len(m.lines))
+		for line, included := range m.included {
+			if included {
+				lines = append(lines, line)
+			}
+		}
+		return lines
+	}
+	// Show lines up to Context lines away from an included line
+	var lines []int
+	until := -1
+	for line := range m.lines {
+		if line > until {
+			for next := line; next <= min(line+m.filter.Context, len(m.lines)-1); next++ {
+				if m.included[next] {
+					until = next + m.filter.Context
+					break
+				}
+			}
+		} else if m.included[line] {
+			until = line + m.filter.Context
+		}
+		if line <= until && !m.excluded[line] {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,80 @@
+package jlsviewport
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+)
+
+func patterns(exprs ...string) []*regexp.Regexp {
+	compiled := make([]*regexp.Regexp, len(exprs))
+	for i, expr := range exprs {
+		compiled[i] = regexp.MustCompile(expr)
+	}
+	return compiled
+}
+
+func TestFilter(t *testing.T) {
+	tests := []struct {
+		name    string
+		include []string
+		exclude []string
+		context int
+		want    []int
+	}{
+		{"include", []string{`^line [37]$`}, nil, 0, []int{3, 7}},
+		{"all includes", []string{`line`, `5`}, nil, 0, []int{5}},
+		{"context", []string{`^line [37]$`}, nil, 1, []int{2, 3, 4, 6, 7, 8}},
+		{"overlapping context", []string{`^line [37]$`}, nil, 2, []int{1, 2, 3, 4, 5, 6, 7, 8, 9}},
+		{"context at the start", []string{`^line 0$`}, nil, 2, []int{0, 1, 2}},
+		{"context at the end", []string{`^line 9$`}, nil, 2, []int{7, 8, 9}},
+		{"exclude", nil, []string{`^line [2-8]$`}, 0, []int{0, 1, 9}},
+		{"context ignored without include", nil, []string{`^line [2-8]$`}, 1, []int{0, 1, 9}},
+		{"exclude in context", []string{`^line [37]$`}, []string{`^line [48]$`}, 1, []int{2, 3, 6, 7}},
+		{"exclude an included line", []string{`^line 3$`}, []string{`3`}, 1, []int{}},
+		{"nothing included", []string{`nothing`}, nil, 2, []int{}},
+	}
+	for _, tt := range tests {
+		m := newWithContent(40, 5, numberedLines(10, nil))
+		m.SetFilter(Filter{Include: patterns(tt.include...), Exclude: patterns(tt.exclude...), Context: tt.context})
+		got := m.ShownLines()
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: shows lines %v, want %v", tt.name, got, tt.want)
+		}
+		if m.ShownLineCount() != len(tt.want) {
+			t.Errorf("%s: ShownLineCount() = %d, want %d", tt.name, m.ShownLineCount(), len(tt.want))
+		}
+	}
+}
+
+func TestFilterStreaming(t *testing.T) {
+	m := newWithContent(40, 5, "ok\nerror 1\nok\ner")
+	m.SetFilter(Filter{Include: patterns(`error`), Context: 1})
+	if got, want := m.ShownLines(), []int{0, 1, 2}; !slices.Equal(got, want) {
+		t.Errorf("shows lines %v, want %v", got, want)
+	}
+	// The last line is completed, and more lines arrive
+	m.SetContent("ok\nerror 1\nok\nerror 2\nok\nok\nok")
+	if got, want := m.ShownLines(), []int{0, 1, 2, 3, 4}; !slices.Equal(got, want) {
+		t.Errorf("after more content, shows lines %v, want %v", got, want)
+	}
+
+	m.SetFilter(Filter{})
+	if got := len(m.ShownLines()); got != 7 {
+		t.Errorf("without a filter, shows %d lines, want all 7", got)
+	}
+}
+
+func TestFilterIgnoresFolds(t *testing.T) {
+	m := newWithContent(40, 5, numberedLines(10, nil))
+	m.SetFolds([]Fold{{Start: 2, End: 6, Collapsed: true}})
+	m.SetFilter(Filter{Include: patterns(`^line [34]$`)})
+	if got, want := m.ShownLines(), []int{3, 4}; !slices.Equal(got, want) {
+		t.Errorf("shows lines %v, want %v", got, want)
+	}
+	for i := range m.rows {
+		if text := m.renderRow(i); text != m.lines[m.rows[i].line] {
+			t.Errorf("row %d is shown as %q, want the line without a fold summary", i, text)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package jlsviewport
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+)
+
+// rowTexts returns the text of every row, with plain fold summaries.
+func rowTexts(m Model) []string {
+	m.FoldStyle = lipgloss.NewStyle()
+	texts := make([]string, len(m.rows))
+	for i := range m.rows {
+		texts[i] = m.renderRow(i)
+	}
+	return texts
+}
+
+// summary is the text of a collapsed fold starting on line.
+func summary(line, hidden int) string {
+	return fmt.Sprintf("line %d\x1b[0m ⋯ %d more lines", line, hidden)
+}
+
+func TestFolds(t *testing.T) {
+	tests := []struct {
+		name  string
+		folds []Fold
+		want  []string
+	}{
+		{"expanded", []Fold{{Start: 2, End: 5}}, []string{"line 0", "line 1", "line 2", "line 3", "line 4", "line 5", "line 6", "line 7"}},
+		{"collapsed", []Fold{{Start: 2, End: 5, Collapsed: true}}, []string{"line 0", "line 1", summary(2, 3), "line 6", "line 7"}},
+		{
+			"nested, both collapsed",
+			[]Fold{{Start: 1, End: 6, Collapsed: true}, {Start: 3, End: 4, Collapsed: true}},
+			[]string{"line 0", summary(1, 5), "line 7"},
+		},
+		{
+			"nested, inner collapsed",
+			[]Fold{{Start: 1, End: 6}, {Start: 3, End: 4, Collapsed: true}},
+			[]string{"line 0", "line 1", "line 2", summary(3, 1), "line 5", "line 6", "line 7"},
+		},
+		{
+			"same start, outer collapsed",
+			[]Fold{{Start: 2, End: 6, Collapsed: true}, {Start: 2, End: 3}},
+			[]string{"line 0", "line 1", summary(2, 4), "line 7"},
+		},
+		{"open", []Fold{{Start: 5, End: -1, Collapsed: true}}, []string{"line 0", "line 1", "line 2", "line 3", "line 4", summary(5, 2)}},
+		{"past the end", []Fold{{Start: 6, End: 20, Collapsed: true}}, []string{"line 0", "line 1", "line 2", "line 3", "line 4", "line 5", summary(6, 1)}},
+	}
+	for _, tt := range tests {
+		m := newWithContent(40, 10, numberedLines(8, nil))
+		m.SetFolds(tt.folds)
+		if got := rowTexts(m); !slices.Equal(got, tt.want) {
+			t.Errorf("%s: shows %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestToggleFold(t *testing.T) {
+	m := newWithContent(40, 3, numberedLines(20, nil))
+	m.SetFolds([]Fold{{Start: 1, End: 10}, {Start: 3, End: 5}, {Start: 12, End: 15}})
+
+	// The first fold starting on screen
+	m.ToggleFold()
+	if got := rowTexts(m)[:3]; !slices.Equal(got, []string{"line 0", summary(1, 9), "line 11"}) {
+		t.Errorf("after collapsing, shows %q", got)
+	}
+	m.ToggleFold()
+	if m.folds[0].Collapsed {
+		t.Errorf("toggling again didn't expand the fold")
+	}
+
+	// The innermost fold around the top line, when none starts on screen
+	m.SetYOffset(4)
+	m.ToggleFold()
+	if !m.folds[1].Collapsed || m.folds[0].Collapsed {
+		t.Errorf("collapsed %+v, want the fold from line 3", m.folds)
+	}
+	if top := m.rows[m.YOffset].line; top != 3 {
+		t.Errorf("line %d is at the top, want the summary on line 3", top)
+	}
+}
+
+func TestFoldsKeepState(t *testing.T) {
+	m := newWithContent(40, 10, numberedLines(5, nil))
+	m.SetFolds([]Fold{{Start: 1, End: -1, Collapsed: true}})
+	// More lines arrive and the fold closes, as when a stage finishes
+	m.SetContent(numberedLines(8, nil))
+	m.SetFolds([]Fold{{Start: 1, End: 5}})
+	want := []string{"line 0", summary(1, 4), "line 6", "line 7"}
+	if got := rowTexts(m); !slices.Equal(got, want) {
+		t.Errorf("shows %q, want %q", got, want)
+	}
+}
+
+func TestFoldsUnderFilter(t *testing.T) {
+	m := newWithContent(40, 10, numberedLines(8, nil))
+	m.SetFolds([]Fold{{Start: 2, End: 5, Collapsed: true}})
+	m.SetFilter(Filter{Include: patterns(`^line [345]$`)})
+	if got, want := rowTexts(m), []string{"line 3", "line 4", "line 5"}; !slices.Equal(got, want) {
+		t.Errorf("with a filter, shows %q, want %q", got, want)
+	}
+	m.SetFilter(Filter{})
+	if got, want := rowTexts(m), []string{"line 0", "line 1", summary(2, 3), "line 6", "line 7"}; !slices.Equal(got, want) {
+		t.Errorf("after the filter, shows %q, want %q", got, want)
+	}
+}
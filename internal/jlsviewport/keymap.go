@@ -19,6 +19,20 @@ type KeyMap struct {
 	Up           key.Binding
 	GotoTop      key.Binding
 	GotoBottom   key.Binding
+	ToggleFold   key.Binding
+	CollapseAll  key.Binding
+	ExpandAll    key.Binding
+	ToggleWrap   key.Binding
+	ToggleColor  key.Binding
+	NextMatch    key.Binding
+	PrevMatch    key.Binding
+	MoreContext  key.Binding
+	LessContext  key.Binding
+	NextMark     key.Binding
+	PrevMark     key.Binding
+	Select       key.Binding
+	SelectScreen key.Binding
+	Copy         key.Binding
 }
 
 // DefaultKeyMap returns a set of pager-like default keybindings.
@@ -55,5 +69,61 @@ func DefaultKeyMap() KeyMap {
 		GotoBottom: key.NewBinding(
 			key.WithKeys("end", "G"),
 			key.WithHelp("G/end", "go to bottom")),
+		ToggleFold: key.NewBinding(
+			key.WithKeys("z"),
+			key.WithHelp("z", "fold/unfold section"),
+		),
+		CollapseAll: key.NewBinding(
+			key.WithKeys("Z"),
+			key.WithHelp("Z", "fold all sections"),
+		),
+		ExpandAll: key.NewBinding(
+			key.WithKeys("X"),
+			key.WithHelp("X", "unfold all sections"),
+		),
+		ToggleWrap: key.NewBinding(
+			key.WithKeys("w"),
+			key.WithHelp("w", "wrap/truncate lines"),
+		),
+		ToggleColor: key.NewBinding(
+			key.WithKeys("c"),
+			key.WithHelp("c", "show/hide colors"),
+		),
+		NextMatch: key.NewBinding(
+			key.WithKeys("n"),
+			key.WithHelp("n", "next match"),
+		),
+		PrevMatch: key.NewBinding(
+			key.WithKeys("N"),
+			key.WithHelp("N", "previous match"),
+		),
+		MoreContext: key.NewBinding(
+			key.WithKeys("+"),
+			key.WithHelp("+", "more filter context"),
+		),
+		LessContext: key.NewBinding(
+			key.WithKeys("-"),
+			key.WithHelp("-", "less filter context"),
+		),
+		NextMark: key.NewBinding(
+			key.WithKeys("e"),
+			key.WithHelp("e", "next problem"),
+		),
+		PrevMark: key.NewBinding(
+			key.WithKeys("E"),
+			key.WithHelp("E", "previous problem"),
+		),
+		Select: key.NewBinding(
+			key.WithKeys("v"),
+			key.WithHelp("v", "select lines"),
+		),
+		SelectScreen: key.NewBinding(
+			key.WithKeys("V"),
+			key.WithHelp("V", "select screen"),
+		),
+		Copy: key.NewBinding(
+			key.WithKeys("y", "enter"),
+			key.WithHelp("y", "copy selection"),
+		),
 	}
 }
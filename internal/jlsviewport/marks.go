@@ -0,0 +1,121 @@
+package jlsviewport
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// Marker marks the lines matching any of its patterns, such as errors, so
+// they stand out and can be jumped to.
+type Marker struct {
+	Patterns []*regexp.Regexp
+	// Style is the SGR sequence marked lines are shown in
+	Style string
+}
+
+// SetMarkers sets the markers to mark lines with. A line matching several
+// markers is marked by the first.
+func (m *Model) SetMarkers(markers []Marker) {
+	m.markers = markers
+	m.marks = nil
+	m.marked = nil
+	m.updateMarks(0)
+}
+
+// MarkedLines returns the number of lines each marker marked.
+func (m Model) MarkedLines() []int {
+	counts := make([]int, len(m.markers))
+	for _, line := range m.marked {
+		counts[m.marks[line]]++
+	}
+	return counts
+}
+
+// FirstMark returns the first line marked by marker i, or -1 if there is
+// none.
+func (m Model) FirstMark(i int) int {
+	for _, line := range m.marked {
+		if m.marks[line] == i {
+			return line
+		}
+	}
+	return -1
+}
+
+// NextMark moves to the next marked line after the top of the screen, or
+// after the marked line last moved to if it's still on screen.
+func (m *Model) NextMark() {
+	from := m.markFrom()
+	i := sort.SearchInts(m.marked, from+1)
+	if i < len(m.marked) {
+		m.moveToMark(m.marked[i])
+	}
+}
+
+// PrevMark moves to the previous marked line before the top of the screen,
+// or before the marked line last moved to if it's still on screen.
+func (m *Model) PrevMark() {
+	from := m.markFrom()
+	i := sort.SearchInts(m.marked, from) - 1
+	if i >= 0 {
+		m.moveToMark(m.marked[i])
+	}
+}
+
+func (m *Model) moveToMark(line int) {
+	m.ShowLine(line)
+	m.SetYOffset(m.rowIndex(line))
+	m.mark = line
+}
+
+// markFrom returns the line to look for the next or previous mark from.
+func (m Model) markFrom() int {
+	if len(m.rows) == 0 {
+		return 0
+	}
+	top := m.rows[clamp(m.YOffset, 0, len(m.rows)-1)].line
+	bottom := m.rows[clamp(m.lastVisibleRow(), 0, len(m.rows)-1)].line
+	if m.mark >= top && m.mark <= bottom {
+		return m.mark
+	}
+	return top
+}
+
+// updateMarks matches the lines from first on, which have changed since
+// they were last matched, against the markers.
+func (m *Model) updateMarks(first int) {
+	if len(m.markers) == 0 {
+		return
+	}
+	first = min(first, len(m.marks))
+	m.marks = m.marks[:first]
+	m.marked = m.marked[:sort.SearchInts(m.marked, first)]
+	for line := first; line < len(m.lines); line++ {
+		mark := m.markLine(ansi.Strip(m.lines[line]))
+		m.marks = append(m.marks, mark)
+		if mark >= 0 {
+			m.marked = append(m.marked, line)
+		}
+	}
+}
+
+func (m Model) markLine(text string) int {
+	for i, marker := range m.markers {
+		for _, pattern := range marker.Patterns {
+			if pattern.MatchString(text) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// markStyle returns the style of the marker that marked line, if any.
+func (m Model) markStyle(line int) string {
+	if line < len(m.marks) && m.marks[line] >= 0 {
+		return m.markers[m.marks[line]].Style
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+package jlsviewport
+
+import (
+	"regexp"
+	"sort"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// match is a match of the search pattern, in bytes of the line with its
+// escape sequences removed.
+type match struct {
+	line  int
+	start int
+	end   int
+}
+
+// Search highlights the matches of pattern and moves to the first one at or
+// after the top of the screen, or with backward, the last one before it. A
+// nil pattern clears the search. It returns false if nothing matches.
+func (m *Model) Search(pattern *regexp.Regexp, backward bool) bool {
+	m.search = pattern
+	m.backward = backward
+	m.matches = nil
+	m.current = -1
+	m.updateMatches(0)
+	if pattern == nil || len(m.matches) == 0 {
+		return false
+	}
+	top := 0
+	if m.YOffset >= 0 && m.YOffset < len(m.rows) {
+		top = m.rows[m.YOffset].line
+	}
+	first := sort.Search(len(m.matches), func(i int) bool {
+		return m.matches[i].line >= top
+	})
+	if backward {
+		first--
+	}
+	m.moveToMatch(first)
+	return true
+}
+
+// SearchPattern returns the pattern being searched for, or nil.
+func (m Model) SearchPattern() *regexp.Regexp {
+	return m.search
+}
+
+// SearchMatches returns the position of the current match, counting from 1,
+// and the number of matches. The position is 0 before moving to a match.
+func (m Model) SearchMatches() (current, total int) {
+	return m.current + 1, len(m.matches)
+}
+
+// NextMatch moves to the next match in the direction of the search,
+// wrapping around at the end.
+func (m *Model) NextMatch() {
+	if m.backward {
+		m.moveToMatch(m.current - 1)
+	} else {
+		m.moveToMatch(m.current + 1)
+	}
+}
+
+// PrevMatch moves to the next match against the direction of the search,
+// wrapping around at the end.
+func (m *Model) PrevMatch() {
+	if m.backward {
+		m.moveToMatch(m.current + 1)
+	} else {
+		m.moveToMatch(m.current - 1)
+	}
+}
+
+func (m *Model) moveToMatch(i int) {
+	if len(m.matches) == 0 {
+		return
+	}
+	m.current = (i + len(m.matches)) % len(m.matches)
+	m.ShowLine(m.matches[m.current].line)
+}
+
+// ShowLine scrolls line into view, expanding any folds it's hidden in.
+func (m *Model) ShowLine(line int) {
+	expanded := false
+	for i, f := range m.folds {
+		if f.Start > line {
+			break
+		}
+		if f.Collapsed && f.Start < line && m.foldEnd(i) >= line {
+			m.folds[i].Collapsed = false
+			expanded = true
+		}
+	}
+	if expanded {
+		m.updateRows()
+	}
+	row := m.rowIndex(line)
+	if row < m.YOffset || row > m.lastVisibleRow() {
+		m.SetYOffset(row)
+	}
+}
+
+// lastVisibleRow returns the last row that fits on screen, taking wrapped
+// rows into account.
+func (m Model) lastVisibleRow() int {
+	height := 0
+	for i := max(0, m.YOffset); i < len(m.rows); i++ {
+		height += len(m.wrapRow(i))
+		if height > m.Height {
+			return i - 1
+		}
+	}
+	return len(m.rows) - 1
+}
+
+// updateMatches searches the lines from first on, which have changed since
+// they were last searched.
+func (m *Model) updateMatches(first int) {
+	if m.search == nil {
+		return
+	}
+	kept := sort.Search(len(m.matches), func(i int) bool {
+		return m.matches[i].line >= first
+	})
+	m.matches = m.matches[:kept]
+	for line := first; line < len(m.lines); line++ {
+		for _, loc := range m.search.FindAllStringIndex(ansi.Strip(m.lines[line]), -1) {
+			if loc[1] > loc[0] {
+				m.matches = append(m.matches, match{line: line, start: loc[0], end: loc[1]})
+			}
+		}
+	}
+	if m.current >= len(m.matches) {
+		m.current = len(m.matches) - 1
+	}
+}
+
+// highlightMatches highlights the matches in a line of content.
+func (m Model) highlightMatches(line int, text string) string {
+	i := sort.Search(len(m.matches), func(i int) bool {
+		return m.matches[i].line >= line
+	})
+	var spans []ansi.Span
+	for ; i < len(m.matches) && m.matches[i].line == line; i++ {
+		style := m.MatchStyle
+		if i == m.current {
+			style = m.CurrentMatchStyle
+		}
+		spans = append(spans, ansi.Span{Start: m.matches[i].start, End: m.matches[i].end, Style: style})
+	}
+	return ansi.Highlight(text, spans)
+}
+
+// firstChange returns the index of the first line that differs between old
+// and lines.
+func firstChange(old, lines []string) int {
+	i := 0
+	for i < len(old) && i < len(lines) && old[i] == lines[i] {
+		i++
+	}
+	return i
+}
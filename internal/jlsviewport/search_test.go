@@ -0,0 +1,138 @@
+package jlsviewport
+
+import (
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// errorLines is a log with matches of "error" on lines 3, 12 and 25, twice on
+// line 12.
+var errorLines = numberedLines(30, map[int]string{
+	3:  "error: one",
+	12: "\x1b[31merror\x1b[0m and error",
+	25: "an error",
+})
+
+// matchLine returns the line of the current match, or -1.
+func matchLine(m Model) int {
+	if m.current < 0 {
+		return -1
+	}
+	return m.matches[m.current].line
+}
+
+func TestSearch(t *testing.T) {
+	tests := []struct {
+		name     string
+		pattern  string
+		backward bool
+		top      int
+		want     int
+		found    bool
+	}{
+		{"forward from the top", "error", false, 0, 3, true},
+		{"forward from the middle", "error", false, 10, 12, true},
+		{"backward", "error", true, 10, 3, true},
+		{"forward past the last match", "error", false, 25, 25, true},
+		{"backward from the top wraps", "error", true, 0, 25, true},
+		{"forward past the end wraps", "one", false, 20, 3, true},
+		{"no match", "warning", false, 0, -1, false},
+	}
+	for _, tt := range tests {
+		m := newWithContent(40, 5, errorLines)
+		m.SetYOffset(tt.top)
+		found := m.Search(regexp.MustCompile(tt.pattern), tt.backward)
+		if found != tt.found || matchLine(m) != tt.want {
+			t.Errorf("%s: Search(%q) = %v at line %d, want %v at line %d", tt.name, tt.pattern, found, matchLine(m), tt.found, tt.want)
+		}
+		if tt.found && !onScreen(m, tt.want) {
+			t.Errorf("%s: line %d of the match isn't on screen", tt.name, tt.want)
+		}
+	}
+}
+
+func TestNextMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		backward bool
+		next     bool
+		want     []int
+	}{
+		{"next", false, true, []int{12, 12, 25, 3, 12}},
+		{"previous", false, false, []int{25, 12, 12, 3, 25}},
+		{"next backward", true, true, []int{25, 12, 12, 3, 25}},
+		{"previous backward", true, false, []int{12, 12, 25, 3, 12}},
+	}
+	for _, tt := range tests {
+		m := newWithContent(40, 5, errorLines)
+		m.Search(regexp.MustCompile("error"), tt.backward)
+		if tt.backward {
+			// Start from the first match, like searching forward
+			m.moveToMatch(0)
+		}
+		var got []int
+		for range tt.want {
+			if tt.next {
+				m.NextMatch()
+			} else {
+				m.PrevMatch()
+			}
+			got = append(got, matchLine(m))
+			if !onScreen(m, matchLine(m)) {
+				t.Errorf("%s: line %d of the match isn't on screen", tt.name, matchLine(m))
+			}
+		}
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("%s: moved to lines %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSearchStreaming(t *testing.T) {
+	m := newWithContent(40, 5, "error 1\nok\nerr")
+	m.Search(regexp.MustCompile("error"), false)
+	if current, total := m.SearchMatches(); current != 1 || total != 1 {
+		t.Fatalf("SearchMatches() = %d, %d, want 1, 1", current, total)
+	}
+	// The last line is completed, and more lines arrive
+	m.SetContent("error 1\nok\nerror 2\nok\nerror 3")
+	if current, total := m.SearchMatches(); current != 1 || total != 3 {
+		t.Errorf("after more content, SearchMatches() = %d, %d, want 1, 3", current, total)
+	}
+	m.NextMatch()
+	m.NextMatch()
+	if got := matchLine(m); got != 4 {
+		t.Errorf("the third match is on line %d, want 4", got)
+	}
+	// Content replaced by less of it, such as another build
+	m.SetContent("error 1")
+	if current, total := m.SearchMatches(); current != 1 || total != 1 {
+		t.Errorf("after less content, SearchMatches() = %d, %d, want 1, 1", current, total)
+	}
+}
+
+func TestSearchWrappedRows(t *testing.T) {
+	// Every line takes three screen lines at width 10
+	long := strings.Repeat("x", 25)
+	m := newWithContent(10, 6, numberedLines(20, map[int]string{
+		0: long, 1: long, 2: long, 3: long, 4: long, 5: long, 6: long, 7: long + " error",
+	}))
+	m.Search(regexp.MustCompile("error"), false)
+	if !onScreen(m, 7) {
+		t.Errorf("line 7 of the match isn't on screen, the screen shows rows %d to %d", m.YOffset, m.lastVisibleRow())
+	}
+}
+
+func TestHighlightMatches(t *testing.T) {
+	m := newWithContent(40, 5, errorLines)
+	m.Search(regexp.MustCompile("error"), false)
+	m.NextMatch()
+	got := m.renderRow(m.rowIndex(12))
+	// The line's own color is restored after the current match
+	want := "\x1b[31m" + m.CurrentMatchStyle + "error\x1b[0m\x1b[31m\x1b[0m and " + m.MatchStyle + "error\x1b[0m"
+	if got != want {
+		t.Errorf("line 12 is shown as %q, want %q", got, want)
+	}
+}
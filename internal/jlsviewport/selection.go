@@ -0,0 +1,210 @@
+package jlsviewport
+
+import (
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// CopyMsg is sent when the selected lines are copied. The viewport leaves
+// putting Text on the clipboard to the program.
+type CopyMsg struct {
+	Text  string
+	Lines int
+}
+
+// selection is a range of lines selected for copying, from the line it was
+// started on to the line the cursor is on, in either order.
+type selection struct {
+	anchor int
+	cursor int
+	// dragging is set while the selection follows the mouse
+	dragging bool
+}
+
+// lines returns the first and last line of the selection.
+func (s selection) lines() (first, last int) {
+	return min(s.anchor, s.cursor), max(s.anchor, s.cursor)
+}
+
+// Select starts selecting lines at the top of the screen, or with screen,
+// selects the lines on screen. The cursor end of the selection moves with
+// the keys that otherwise scroll.
+func (m *Model) Select(screen bool) {
+	if len(m.rows) == 0 {
+		return
+	}
+	top := clamp(m.YOffset, 0, len(m.rows)-1)
+	m.selection = &selection{anchor: m.rows[top].line, cursor: m.rows[top].line}
+	if screen {
+		m.selection.cursor = m.rows[clamp(m.lastVisibleRow(), top, len(m.rows)-1)].line
+	}
+}
+
+// Selecting reports whether lines are selected.
+func (m Model) Selecting() bool {
+	return m.selection != nil
+}
+
+// SelectedLines returns the number of lines shown in the selection.
+func (m Model) SelectedLines() int {
+	return len(m.selectedRows())
+}
+
+// ClearSelection stops selecting lines.
+func (m *Model) ClearSelection() {
+	m.selection = nil
+}
+
+// Copy ends the selection, returning a command that sends the text of the
+// selected lines, without escape sequences, in a CopyMsg. The lines hidden
+// in a collapsed fold are copied with it, the lines hidden by the filter
+// aren't.
+func (m *Model) Copy() tea.Cmd {
+	rows := m.selectedRows()
+	m.selection = nil
+	if len(rows) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, i := range rows {
+		last := m.rows[i].line
+		if m.rows[i].fold >= 0 {
+			last = m.foldEnd(m.rows[i].fold)
+		}
+		for line := m.rows[i].line; line <= last; line++ {
+			lines = append(lines, ansi.Strip(m.lines[line]))
+		}
+	}
+	msg := CopyMsg{Text: strings.Join(lines, "\n"), Lines: len(lines)}
+	return func() tea.Msg {
+		return msg
+	}
+}
+
+// selectedRows returns the indexes of the rows in the selection.
+func (m Model) selectedRows() []int {
+	if m.selection == nil {
+		return nil
+	}
+	first, last := m.selection.lines()
+	var rows []int
+	for i := m.rowIndex(first); i < len(m.rows) && m.rows[i].line <= last; i++ {
+		rows = append(rows, i)
+	}
+	return rows
+}
+
+// selected reports whether the row showing line is in the selection.
+func (m Model) selected(line int) bool {
+	if m.selection == nil {
+		return false
+	}
+	first, last := m.selection.lines()
+	return line >= first && line <= last
+}
+
+// moveCursor moves the cursor end of the selection by n rows, scrolling to
+// keep it on screen.
+func (m *Model) moveCursor(n int) {
+	if len(m.rows) == 0 {
+		return
+	}
+	row := clamp(m.rowIndex(m.selection.cursor)+n, 0, len(m.rows)-1)
+	m.selection.cursor = m.rows[row].line
+	if row < m.YOffset {
+		m.SetYOffset(row)
+	}
+	for row > m.lastVisibleRow() && m.YOffset < m.maxYOffset() {
+		m.SetYOffset(m.YOffset + 1)
+	}
+}
+
+// updateSelection handles the keys that move the cursor end of the
+// selection, and copying it. It reports whether the key was handled.
+func (m *Model) updateSelection(msg tea.KeyMsg) (bool, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.KeyMap.Copy):
+		return true, m.Copy()
+	case key.Matches(msg, m.KeyMap.Select):
+		m.ClearSelection()
+	case key.Matches(msg, m.KeyMap.Down):
+		m.moveCursor(1)
+	case key.Matches(msg, m.KeyMap.Up):
+		m.moveCursor(-1)
+	case key.Matches(msg, m.KeyMap.PageDown):
+		m.moveCursor(m.Height)
+	case key.Matches(msg, m.KeyMap.PageUp):
+		m.moveCursor(-m.Height)
+	case key.Matches(msg, m.KeyMap.HalfPageDown):
+		m.moveCursor(m.Height / 2)
+	case key.Matches(msg, m.KeyMap.HalfPageUp):
+		m.moveCursor(-m.Height / 2)
+	case key.Matches(msg, m.KeyMap.GotoTop):
+		m.moveCursor(-len(m.rows))
+	case key.Matches(msg, m.KeyMap.GotoBottom):
+		m.moveCursor(len(m.rows))
+	default:
+		return false, nil
+	}
+	return true, nil
+}
+
+// updateDrag selects the lines the mouse is dragged over with the left
+// button, copying them when it's released.
+func (m *Model) updateDrag(msg tea.MouseMsg) tea.Cmd {
+	if msg.Button != tea.MouseButtonLeft && msg.Action != tea.MouseActionRelease {
+		return nil
+	}
+	row := m.rowAt(msg.Y)
+	switch msg.Action {
+	case tea.MouseActionPress:
+		if row < 0 || msg.X >= m.Width {
+			m.selection = nil
+			return nil
+		}
+		m.selection = &selection{anchor: m.rows[row].line, cursor: m.rows[row].line, dragging: true}
+	case tea.MouseActionMotion:
+		if m.selection == nil || !m.selection.dragging {
+			return nil
+		}
+		// Dragging past the top or bottom scrolls
+		switch {
+		case msg.Y < 0:
+			m.moveCursor(-1)
+		case msg.Y >= m.Height:
+			m.moveCursor(1)
+		case row >= 0:
+			m.selection.cursor = m.rows[row].line
+		}
+	case tea.MouseActionRelease:
+		if m.selection == nil || !m.selection.dragging {
+			return nil
+		}
+		if m.selection.anchor == m.selection.cursor {
+			// A click selects nothing
+			m.selection = nil
+			return nil
+		}
+		return m.Copy()
+	}
+	return nil
+}
+
+// rowAt returns the row shown y lines from the top of the viewport, or -1 if
+// there's none.
+func (m Model) rowAt(y int) int {
+	if y < 0 {
+		return -1
+	}
+	height := 0
+	for i := max(0, m.YOffset); i < len(m.rows) && height < m.Height; i++ {
+		height += len(m.wrapRow(i))
+		if y < height {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,105 @@
+package jlsviewport
+
+import (
+	"strings"
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// copied runs the command returned by Copy and returns its CopyMsg.
+func copied(t *testing.T, m *Model) CopyMsg {
+	t.Helper()
+	cmd := m.Copy()
+	if cmd == nil {
+		t.Fatal("Copy returned no command")
+	}
+	return cmd().(CopyMsg)
+}
+
+// longLines returns n lines of 15 characters, two rows each at width 10.
+func longLines(n int) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = strings.Repeat(string(rune('a'+i)), 15)
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestSelectScreen(t *testing.T) {
+	tests := []struct {
+		name    string
+		width   int
+		content string
+		filter  Filter
+		want    string
+	}{
+		{"one row a line", 40, numberedLines(10, nil), Filter{}, "line 0\nline 1\nline 2\nline 3"},
+		{"wrapped", 10, longLines(10), Filter{}, "aaaaaaaaaaaaaaa\nbbbbbbbbbbbbbbb"},
+		{"filtered", 40, numberedLines(10, nil), Filter{Include: patterns(`[13579]$`)}, "line 1\nline 3\nline 5\nline 7"},
+	}
+	for _, tt := range tests {
+		m := newWithContent(tt.width, 4, tt.content)
+		m.SetFilter(tt.filter)
+		m.Select(true)
+		if got := copied(t, &m); got.Text != tt.want || got.Lines != strings.Count(tt.want, "\n")+1 {
+			t.Errorf("%s: copied %d lines, %q, want %q", tt.name, got.Lines, got.Text, tt.want)
+		}
+		if m.Selecting() {
+			t.Errorf("%s: still selecting after copying", tt.name)
+		}
+	}
+}
+
+func TestSelectMovesCursor(t *testing.T) {
+	// Each line takes two rows, so two lines fit on screen
+	m := newWithContent(10, 4, longLines(10))
+	m.SetFilter(Filter{Exclude: patterns(`^c`)})
+	m.Select(false)
+	for i := 0; i < 3; i++ {
+		m.moveCursor(1)
+	}
+	if m.selection.cursor != 4 {
+		t.Errorf("cursor on line %d, want line 4, skipping the filtered line 2", m.selection.cursor)
+	}
+	if !onScreen(m, 4) {
+		t.Errorf("line 4 isn't on screen at offset %d", m.YOffset)
+	}
+	if got := m.SelectedLines(); got != 4 {
+		t.Errorf("%d lines selected, want 4", got)
+	}
+	if got := copied(t, &m).Text; got != "aaaaaaaaaaaaaaa\nbbbbbbbbbbbbbbb\nddddddddddddddd\neeeeeeeeeeeeeee" {
+		t.Errorf("copied %q", got)
+	}
+}
+
+func TestSelectDrag(t *testing.T) {
+	m := newWithContent(10, 6, longLines(10))
+	m.updateDrag(tea.MouseMsg{X: 2, Y: 1, Button: tea.MouseButtonLeft, Action: tea.MouseActionPress})
+	// The second row of line 1 is the fourth on screen
+	m.updateDrag(tea.MouseMsg{X: 2, Y: 3, Button: tea.MouseButtonLeft, Action: tea.MouseActionMotion})
+	cmd := m.updateDrag(tea.MouseMsg{X: 2, Y: 3, Action: tea.MouseActionRelease})
+	if cmd == nil {
+		t.Fatal("releasing the button copied nothing")
+	}
+	if got := cmd().(CopyMsg); got.Text != "aaaaaaaaaaaaaaa\nbbbbbbbbbbbbbbb" || got.Lines != 2 {
+		t.Errorf("copied %d lines, %q", got.Lines, got.Text)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	content := numberedLines(8, map[int]string{
+		0: "\x1b[31mERROR\x1b[0m: \x1b[1mboom\x1b[0m",
+		1: "see " + ansi.LinkStart("https://ci.example.com/job/x/1/") + "build 1" + ansi.LinkEnd,
+	})
+	m := newWithContent(40, 10, content)
+	m.SetFolds([]Fold{{Start: 2, End: 5, Collapsed: true}})
+	m.Select(true)
+	got := copied(t, &m)
+	// The lines in the collapsed fold are copied with its summary
+	want := "ERROR: boom\nsee build 1\nline 2\nline 3\nline 4\nline 5\nline 6\nline 7"
+	if got.Text != want || got.Lines != 8 {
+		t.Errorf("copied %d lines, %q, want %q", got.Lines, got.Text, want)
+	}
+}
@@ -1,12 +1,16 @@
 package jlsviewport
 
 import (
+	"fmt"
 	"math"
+	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/charmbracelet/bubbles/key"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
 )
 
 // This is a copy of
@@ -54,14 +58,84 @@ type Model struct {
 	// which is usually via the alternate screen buffer.
 	HighPerformanceRendering bool
 
+	// FoldStyle styles the line count shown after a collapsed fold.
+	FoldStyle lipgloss.Style
+
+	// NoWrap truncates lines that are too wide instead of wrapping them.
+	NoWrap bool
+
+	// NoColor shows the content without its colors and other SGR styles.
+	NoColor bool
+
+	// Gutter returns the text shown to the left of a line, such as when it
+	// was written. It's cut or padded to GutterWidth cells, and the rows a
+	// wrapped line continues on get an empty gutter.
+	Gutter      func(line int) string
+	GutterWidth int
+	GutterStyle lipgloss.Style
+
+	// MatchStyle and CurrentMatchStyle are the SGR sequences that highlight
+	// the matches of a search, and the match last moved to.
+	MatchStyle        string
+	CurrentMatchStyle string
+
+	// SelectionStyle is the SGR sequence selected lines are shown in.
+	SelectionStyle string
+
 	initialized bool
 	lines       []string
+	folds       []Fold
+	// rows lists what is shown, in order: the lines outside collapsed folds
+	// and a summary row for each collapsed fold. YOffset indexes into rows.
+	rows []row
+	// search is the pattern searched for, matching at matches
+	search   *regexp.Regexp
+	backward bool
+	matches  []match
+	current  int
+	// filter limits the lines in rows, included and excluded record which
+	// lines matched it
+	filter   Filter
+	included []bool
+	excluded []bool
+	// markers mark lines, marks holds the marker of each line or -1, and
+	// marked lists the marked lines. mark is the marked line last moved to.
+	markers []Marker
+	marks   []int
+	marked  []int
+	mark    int
+	// selection is the range of lines selected for copying, or nil
+	selection *selection
+}
+
+// Fold is a range of lines that can be collapsed into a single summary row.
+type Fold struct {
+	// Start is the first line of the fold, shown as its summary when
+	// collapsed
+	Start int
+	// End is the last line of the fold, or -1 if it runs to the end of the
+	// content
+	End       int
+	Collapsed bool
+}
+
+// row is a line of content, or the summary of a collapsed fold.
+type row struct {
+	line int
+	fold int
 }
 
 func (m *Model) setInitialValues() {
 	m.KeyMap = DefaultKeyMap()
 	m.MouseWheelEnabled = true
 	m.MouseWheelDelta = 3
+	m.FoldStyle = lipgloss.NewStyle().Faint(true)
+	m.GutterStyle = lipgloss.NewStyle().Faint(true)
+	m.MatchStyle = "\x1b[30;43m"
+	m.CurrentMatchStyle = "\x1b[30;48;5;208m"
+	m.SelectionStyle = "\x1b[7m"
+	m.current = -1
+	m.mark = -1
 	m.initialized = true
 }
 
@@ -89,13 +163,11 @@ func (m Model) PastBottom() bool {
 
 // ScrollPercent returns the amount scrolled as a float between 0 and 1.
 func (m Model) ScrollPercent() float64 {
-	if m.Height >= len(m.lines) {
+	maxOffset := m.maxYOffset()
+	if maxOffset == 0 {
 		return 1.0
 	}
-	y := float64(m.YOffset)
-	h := float64(m.Height)
-	t := float64(len(m.lines) - 1)
-	v := y / (t - h)
+	v := float64(m.YOffset) / float64(maxOffset)
 	return math.Max(0.0, math.Min(1.0, v))
 }
 
@@ -103,26 +175,243 @@ func (m Model) ScrollPercent() float64 {
 // Sync command should also be called.
 func (m *Model) SetContent(s string) {
 	s = strings.ReplaceAll(s, "\r\n", "\n") // normalize line endings
+	old := m.lines
 	m.lines = strings.Split(s, "\n")
+	first := firstChange(old, m.lines)
+	m.updateMatches(first)
+	m.updateFilter(first)
+	m.updateMarks(first)
+	m.updateRows()
 
-	if m.YOffset > len(m.lines)-1 {
+	if m.YOffset > len(m.rows)-1 {
 		m.GotoBottom()
 	}
 }
 
+// SetFolds sets the ranges of lines that can be collapsed, sorted by their
+// first line. Folds that start on the same line as a current fold keep its
+// collapsed state.
+func (m *Model) SetFolds(folds []Fold) {
+	collapsed := make(map[int]bool, len(m.folds))
+	for _, f := range m.folds {
+		collapsed[f.Start] = f.Collapsed
+	}
+	m.folds = make([]Fold, len(folds))
+	for i, f := range folds {
+		if c, ok := collapsed[f.Start]; ok {
+			f.Collapsed = c
+		}
+		m.folds[i] = f
+	}
+	m.updateRows()
+}
+
+// Folds returns the folds, with their current collapsed state.
+func (m Model) Folds() []Fold {
+	return m.folds
+}
+
+// ToggleFold collapses or expands the first fold that starts on screen, or
+// if there isn't one, the innermost fold around the top line.
+func (m *Model) ToggleFold() {
+	if i := m.foldAtTop(); i >= 0 {
+		m.folds[i].Collapsed = !m.folds[i].Collapsed
+		m.updateRows()
+	}
+}
+
+// SetFoldCollapsed collapses or expands the fold that starts on line.
+func (m *Model) SetFoldCollapsed(line int, collapsed bool) {
+	for i, f := range m.folds {
+		if f.Start == line {
+			m.folds[i].Collapsed = collapsed
+			m.updateRows()
+			return
+		}
+	}
+}
+
+// SetAllFolds collapses or expands every fold.
+func (m *Model) SetAllFolds(collapsed bool) {
+	for i := range m.folds {
+		m.folds[i].Collapsed = collapsed
+	}
+	m.updateRows()
+}
+
+func (m Model) foldAtTop() int {
+	if len(m.rows) == 0 {
+		return -1
+	}
+	top := m.rows[clamp(m.YOffset, 0, len(m.rows)-1)].line
+	bottom := m.rows[clamp(m.YOffset+m.Height-1, 0, len(m.rows)-1)].line
+	innermost := -1
+	for i, f := range m.folds {
+		if f.Start > bottom {
+			break
+		}
+		if f.Start >= top && m.lineShown(f.Start) {
+			return i
+		}
+		if f.Start < top && m.foldEnd(i) >= top {
+			innermost = i
+		}
+	}
+	return innermost
+}
+
+// lineShown reports whether line is outside collapsed folds, or is the
+// summary of one.
+func (m Model) lineShown(line int) bool {
+	i := m.rowIndex(line)
+	return i < len(m.rows) && m.rows[i].line == line
+}
+
+func (m Model) foldEnd(i int) int {
+	if m.folds[i].End < 0 || m.folds[i].End >= len(m.lines) {
+		return len(m.lines) - 1
+	}
+	return m.folds[i].End
+}
+
+// updateRows lists the rows to show, keeping the same line at the top of
+// the viewport.
+func (m *Model) updateRows() {
+	topLine := -1
+	if m.YOffset >= 0 && m.YOffset < len(m.rows) {
+		topLine = m.rows[m.YOffset].line
+	}
+
+	if m.filter.Active() {
+		lines := m.filteredLines()
+		m.rows = make([]row, len(lines))
+		for i, line := range lines {
+			m.rows[i] = row{line: line, fold: -1}
+		}
+		m.keepTopLine(topLine)
+		return
+	}
+
+	m.rows = make([]row, 0, len(m.lines))
+	next := 0
+	for line := 0; line < len(m.lines); line++ {
+		for next < len(m.folds) && m.folds[next].Start < line {
+			next++
+		}
+		fold := -1
+		for i := next; i < len(m.folds) && m.folds[i].Start == line; i++ {
+			if m.folds[i].Collapsed {
+				fold = i
+				break
+			}
+		}
+		m.rows = append(m.rows, row{line: line, fold: fold})
+		if fold >= 0 {
+			line = m.foldEnd(fold)
+		}
+	}
+
+	m.keepTopLine(topLine)
+}
+
+// keepTopLine scrolls to the row showing line, or the one before if it's
+// hidden.
+func (m *Model) keepTopLine(line int) {
+	if line >= 0 && len(m.rows) > 0 {
+		m.YOffset = clamp(m.rowIndex(line), 0, len(m.rows)-1)
+		if m.rows[m.YOffset].line > line && m.YOffset > 0 {
+			m.YOffset--
+		}
+	}
+}
+
+// rowIndex returns the index of the first row showing line or a later one.
+func (m Model) rowIndex(line int) int {
+	return sort.Search(len(m.rows), func(i int) bool {
+		return m.rows[i].line >= line
+	})
+}
+
+// renderRow returns the text shown for a row.
+func (m Model) renderRow(i int) string {
+	r := m.rows[i]
+	text := m.lines[r.line]
+	if m.NoColor {
+		text = ansi.StripStyles(text)
+	}
+	if style := m.markStyle(r.line); style != "" {
+		text = ansi.Highlight(text, []ansi.Span{{Start: 0, End: len(ansi.Strip(text)), Style: style}})
+	}
+	if m.search != nil {
+		text = m.highlightMatches(r.line, text)
+	}
+	if m.selected(r.line) {
+		text = ansi.Highlight(text, []ansi.Span{{Start: 0, End: len(ansi.Strip(text)), Style: m.SelectionStyle}})
+	}
+	if r.fold >= 0 {
+		hidden := m.foldEnd(r.fold) - r.line
+		text += ansi.Reset + m.FoldStyle.Render(fmt.Sprintf(" ⋯ %d more lines", hidden))
+	}
+	return text
+}
+
+// renderRows returns the text of rows top up to, but not including, bottom.
+func (m Model) renderRows(top, bottom int) []string {
+	lines := make([]string, 0, max(0, bottom-top))
+	for i := top; i < bottom; i++ {
+		lines = append(lines, m.renderRow(i))
+	}
+	return lines
+}
+
+func (m Model) contentWidth() int {
+	w := m.Width
+	if sw := m.Style.GetWidth(); sw != 0 {
+		w = min(w, sw)
+	}
+	if m.Gutter != nil {
+		w -= m.GutterWidth
+	}
+	return w - m.Style.GetHorizontalFrameSize()
+}
+
+// gutter returns the gutter shown next to the first screen line of a row.
+func (m Model) gutter(i int) string {
+	g := ansi.Truncate(m.Gutter(m.rows[i].line), m.GutterWidth)
+	g += strings.Repeat(" ", max(0, m.GutterWidth-ansi.Width(g)))
+	return m.GutterStyle.Render(g)
+}
+
+// wrapRow splits a row into the screen lines needed to show it, or just
+// truncates it with NoWrap.
+func (m Model) wrapRow(i int) []string {
+	if m.NoWrap {
+		return []string{ansi.Truncate(m.renderRow(i), m.contentWidth())}
+	}
+	return ansi.Wrap(m.renderRow(i), m.contentWidth())
+}
+
 // maxYOffset returns the maximum possible value of the y-offset based on the
-// viewport's content and set height.
+// viewport's content and set height. Rows that wrap onto several screen lines
+// are taken into account.
 func (m Model) maxYOffset() int {
-	return max(0, len(m.lines)-m.Height)
+	height := 0
+	for i := len(m.rows) - 1; i >= 0; i-- {
+		height += len(m.wrapRow(i))
+		if height > m.Height {
+			return min(i+1, len(m.rows)-1)
+		}
+	}
+	return 0
 }
 
 // visibleLines returns the lines that should currently be visible in the
 // viewport.
 func (m Model) visibleLines() (lines []string) {
-	if len(m.lines) > 0 {
+	if len(m.rows) > 0 {
 		top := max(0, m.YOffset)
-		bottom := clamp(m.YOffset+m.Height, top, len(m.lines))
-		lines = m.lines[top:bottom]
+		bottom := clamp(m.YOffset+m.Height, top, len(m.rows))
+		lines = m.renderRows(top, bottom)
 	}
 	return lines
 }
@@ -181,7 +470,7 @@ func (m *Model) HalfViewUp() (lines []string) {
 
 // LineDown moves the view down by the given number of lines.
 func (m *Model) LineDown(n int) (lines []string) {
-	if m.AtBottom() || n == 0 || len(m.lines) == 0 {
+	if m.AtBottom() || n == 0 || len(m.rows) == 0 {
 		return nil
 	}
 
@@ -191,15 +480,15 @@ func (m *Model) LineDown(n int) (lines []string) {
 	m.SetYOffset(m.YOffset + n)
 
 	// Gather lines to send off for performance scrolling.
-	bottom := clamp(m.YOffset+m.Height, 0, len(m.lines))
+	bottom := clamp(m.YOffset+m.Height, 0, len(m.rows))
 	top := clamp(m.YOffset+m.Height-n, 0, bottom)
-	return m.lines[top:bottom]
+	return m.renderRows(top, bottom)
 }
 
 // LineUp moves the view down by the given number of lines. Returns the new
 // lines to show.
 func (m *Model) LineUp(n int) (lines []string) {
-	if m.AtTop() || n == 0 || len(m.lines) == 0 {
+	if m.AtTop() || n == 0 || len(m.rows) == 0 {
 		return nil
 	}
 
@@ -210,7 +499,7 @@ func (m *Model) LineUp(n int) (lines []string) {
 	// Gather lines to send off for performance scrolling.
 	top := max(0, m.YOffset)
 	bottom := clamp(m.YOffset+n, 0, m.maxYOffset())
-	return m.lines[top:bottom]
+	return m.renderRows(top, bottom)
 }
 
 // TotalLineCount returns the total number of lines (both hidden and visible) within the viewport.
@@ -218,6 +507,21 @@ func (m Model) TotalLineCount() int {
 	return len(m.lines)
 }
 
+// Lines returns the lines of content, which must not be modified.
+func (m Model) Lines() []string {
+	return m.lines
+}
+
+// ShownLines returns the lines shown on the viewport's rows, leaving out the
+// ones hidden by the filter or in collapsed folds.
+func (m Model) ShownLines() []int {
+	lines := make([]int, len(m.rows))
+	for i, r := range m.rows {
+		lines[i] = r.line
+	}
+	return lines
+}
+
 // VisibleLineCount returns the number of the visible lines within the viewport.
 func (m Model) VisibleLineCount() int {
 	return len(m.visibleLines())
@@ -245,7 +549,7 @@ func (m *Model) GotoBottom() (lines []string) {
 //
 // For high performance rendering only.
 func Sync(m Model) tea.Cmd {
-	if len(m.lines) == 0 {
+	if len(m.rows) == 0 {
 		return nil
 	}
 	top, bottom := m.scrollArea()
@@ -295,6 +599,11 @@ func (m Model) updateAsModel(msg tea.Msg) (Model, tea.Cmd) {
 
 	switch msg := msg.(type) {
 	case tea.KeyMsg:
+		if m.selection != nil && !m.selection.dragging {
+			if handled, cmd := m.updateSelection(msg); handled {
+				return m, cmd
+			}
+		}
 		switch {
 		case key.Matches(msg, m.KeyMap.PageDown):
 			lines := m.ViewDown()
@@ -337,9 +646,58 @@ func (m Model) updateAsModel(msg tea.Msg) (Model, tea.Cmd) {
 
 		case key.Matches(msg, m.KeyMap.GotoBottom):
 			m.GotoBottom()
+
+		case key.Matches(msg, m.KeyMap.ToggleFold):
+			m.ToggleFold()
+
+		case key.Matches(msg, m.KeyMap.CollapseAll):
+			m.SetAllFolds(true)
+
+		case key.Matches(msg, m.KeyMap.ExpandAll):
+			m.SetAllFolds(false)
+
+		case key.Matches(msg, m.KeyMap.ToggleWrap):
+			m.NoWrap = !m.NoWrap
+
+		case key.Matches(msg, m.KeyMap.ToggleColor):
+			m.NoColor = !m.NoColor
+
+		case key.Matches(msg, m.KeyMap.NextMatch):
+			m.NextMatch()
+
+		case key.Matches(msg, m.KeyMap.PrevMatch):
+			m.PrevMatch()
+
+		case key.Matches(msg, m.KeyMap.NextMark):
+			m.NextMark()
+
+		case key.Matches(msg, m.KeyMap.PrevMark):
+			m.PrevMark()
+
+		case key.Matches(msg, m.KeyMap.MoreContext):
+			if len(m.filter.Include) > 0 {
+				m.filter.Context++
+				m.refilter()
+			}
+
+		case key.Matches(msg, m.KeyMap.LessContext):
+			if m.filter.Context > 0 {
+				m.filter.Context--
+				m.refilter()
+			}
+
+		case key.Matches(msg, m.KeyMap.Select):
+			m.Select(false)
+
+		case key.Matches(msg, m.KeyMap.SelectScreen):
+			m.Select(true)
 		}
 
 	case tea.MouseMsg:
+		if msg.Button == tea.MouseButtonLeft || msg.Action == tea.MouseActionRelease {
+			cmd = m.updateDrag(msg)
+			break
+		}
 		if !m.MouseWheelEnabled || msg.Action != tea.MouseActionPress {
 			break
 		}
@@ -371,22 +729,39 @@ func (m Model) View() string {
 		return strings.Repeat("\n", max(0, m.Height-1))
 	}
 
-	w, h := m.Width, m.Height
-	if sw := m.Style.GetWidth(); sw != 0 {
-		w = min(w, sw)
-	}
+	h := m.Height
 	if sh := m.Style.GetHeight(); sh != 0 {
 		h = min(h, sh)
 	}
-	contentWidth := w - m.Style.GetHorizontalFrameSize()
 	contentHeight := h - m.Style.GetVerticalFrameSize()
 
-	return lipgloss.NewStyle().
-		Height(contentHeight).    // pad to height.
-		MaxHeight(contentHeight). // truncate height if taller.
-		Width(contentWidth).      // wrap lines, don't truncate width.
-		Render(strings.Join(m.visibleLines(), "\n"))
-
+	// Wrap lines here rather than with lipgloss, which miscounts the width of
+	// OSC 8 hyperlinks and doesn't carry colors over to the next line
+	var lines []string
+	for i := max(0, m.YOffset); i < len(m.rows) && len(lines) < contentHeight; i++ {
+		for j, line := range m.wrapRow(i) {
+			if strings.ContainsRune(line, '\x1b') {
+				line += ansi.Reset
+			}
+			if m.Gutter != nil {
+				if j == 0 {
+					line = m.gutter(i) + line
+				} else {
+					line = strings.Repeat(" ", m.GutterWidth) + line
+				}
+			}
+			lines = append(lines, line)
+		}
+	}
+	lines = lines[:min(len(lines), contentHeight)]
+	for len(lines) < contentHeight {
+		lines = append(lines, "")
+	}
+	content := strings.Join(lines, "\n")
+	if m.Style.GetHorizontalFrameSize() == 0 && m.Style.GetVerticalFrameSize() == 0 {
+		return content
+	}
+	return m.Style.Render(content)
 }
 
 func clamp(v, low, high int) int {
@@ -0,0 +1,54 @@
+package jlsviewport
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+func TestViewFitsWidth(t *testing.T) {
+	content := "java.lang.IllegalStateException: boom\n" +
+		"\tat com.example.Foo.bar(Foo.java:12)\n" +
+		"\t\x1b[31mat\tcom.example.Main.main(Main.java:3)\x1b[0m"
+	for _, noWrap := range []bool{false, true} {
+		m := New(20, 10)
+		m.NoWrap = noWrap
+		m.SetContent(content)
+		for i, line := range strings.Split(m.View(), "\n") {
+			if w := ansi.Width(line); w > m.Width {
+				t.Errorf("NoWrap %v: screen line %d, %q, is %d cells wide, want at most %d", noWrap, i, line, w, m.Width)
+			}
+			if strings.ContainsRune(line, '\t') {
+				t.Errorf("NoWrap %v: screen line %d, %q, has a tab", noWrap, i, line)
+			}
+		}
+	}
+}
+
+// numberedLines returns n lines of content, "line 0" and so on, with the
+// lines in replace swapped for other text.
+func numberedLines(n int, replace map[int]string) string {
+	lines := make([]string, n)
+	for i := range lines {
+		lines[i] = fmt.Sprintf("line %d", i)
+		if text, ok := replace[i]; ok {
+			lines[i] = text
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// newWithContent returns a viewport of the given size showing content.
+func newWithContent(width, height int, content string) Model {
+	m := New(width, height)
+	m.SetContent(content)
+	return m
+}
+
+// onScreen reports whether the row showing line is on screen.
+func onScreen(m Model, line int) bool {
+	row := m.rowIndex(line)
+	return row < len(m.rows) && m.rows[row].line == line && row >= m.YOffset && row <= m.lastVisibleRow()
+}
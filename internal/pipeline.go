@@ -0,0 +1,163 @@
+package jenkins
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"time"
+)
+
+// Stage statuses reported by the Pipeline workflow API
+const (
+	StageSuccess     = "SUCCESS"
+	StageFailed      = "FAILED"
+	StageInProgress  = "IN_PROGRESS"
+	StageAborted     = "ABORTED"
+	StageUnstable    = "UNSTABLE"
+	StageNotExecuted = "NOT_EXECUTED"
+	StagePausedInput = "PAUSED_PENDING_INPUT"
+	workflowRunClass = "org.jenkinsci.plugins.workflow.job.WorkflowRun"
+)
+
+// Build results reported by the Jenkins API once a build finishes
+const (
+	ResultSuccess  = "SUCCESS"
+	ResultUnstable = "UNSTABLE"
+	ResultFailure  = "FAILURE"
+	ResultAborted  = "ABORTED"
+	ResultNotBuilt = "NOT_BUILT"
+)
+
+func pipelineDescribeUrl(url string, build string) string {
+	return buildUrl(url, build) + "/wfapi/describe"
+}
+
+// IsPipeline reports whether the build is a Pipeline run, which can be
+// described with FetchPipeline.
+func (s JobStatus) IsPipeline() bool {
+	return s.Class == workflowRunClass
+}
+
+// PipelineRun describes a Pipeline build and its stages, as returned by the
+// workflow API.
+type PipelineRun struct {
+	Id              string  `json:"id"`
+	Name            string  `json:"name"`
+	Status          string  `json:"status"`
+	StartTimeMillis int64   `json:"startTimeMillis"`
+	DurationMillis  int64   `json:"durationMillis"`
+	Stages          []Stage `json:"stages"`
+}
+
+// Stage is one stage of a Pipeline run.
+type Stage struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	Status          string `json:"status"`
+	StartTimeMillis int64  `json:"startTimeMillis"`
+	DurationMillis  int64  `json:"durationMillis"`
+}
+
+// Duration returns how long the stage took, or has taken so far if it is
+// still running.
+func (s Stage) Duration() time.Duration {
+	if s.Status == StageInProgress && s.StartTimeMillis > 0 {
+		return time.Since(time.UnixMilli(s.StartTimeMillis))
+	}
+	return time.Duration(s.DurationMillis) * time.Millisecond
+}
+
+// FetchPipeline describes the stages of build, which must be a Pipeline run.
+// Jenkins answers with ErrNotFound if the Pipeline Stage View plugin, which
+// provides the workflow API, isn't installed.
+func (c *Client) FetchPipeline(ctx context.Context, build string) (*PipelineRun, error) {
+	run := new(PipelineRun)
+	err := c.getJson(ctx, pipelineDescribeUrl(c.server.JobBaseUrl, build), run)
+	if err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+func stageDescribeUrl(url string, build string, nodeId string) string {
+	return fmt.Sprintf("%s/execution/node/%s/wfapi/describe", buildUrl(url, build), nodeId)
+}
+
+func nodeLogUrl(url string, build string, nodeId string) string {
+	return fmt.Sprintf("%s/execution/node/%s/wfapi/log", buildUrl(url, build), nodeId)
+}
+
+// nodeConsoleUrl returns the URL of the log of a step in its own console,
+// which isn't cut short like the workflow API's.
+func nodeConsoleUrl(url string, build string, nodeId string, html bool) string {
+	if html {
+		return fmt.Sprintf("%s/execution/node/%s/log/logText/progressiveHtml?start=0", buildUrl(url, build), nodeId)
+	}
+	return fmt.Sprintf("%s/execution/node/%s/log/logText/progressiveText?start=0", buildUrl(url, build), nodeId)
+}
+
+// StageDescription lists the steps run by a stage.
+type StageDescription struct {
+	Stage
+	StageFlowNodes []FlowNode `json:"stageFlowNodes"`
+}
+
+// FlowNode is a single step in a Pipeline run.
+type FlowNode struct {
+	Id                   string `json:"id"`
+	Name                 string `json:"name"`
+	Status               string `json:"status"`
+	ParameterDescription string `json:"parameterDescription"`
+}
+
+// NodeLog is the log output of a single step.
+type NodeLog struct {
+	NodeId     string `json:"nodeId"`
+	NodeStatus string `json:"nodeStatus"`
+	Length     int64  `json:"length"`
+	// HasMore is set when the log was cut short, see FetchNodeConsole
+	HasMore bool `json:"hasMore"`
+	// Text is the annotated HTML form of the log
+	Text       string `json:"text"`
+	ConsoleUrl string `json:"consoleUrl"`
+}
+
+// PlainText returns the log with HTML markup removed.
+func (l NodeLog) PlainText() string {
+	return html.UnescapeString(htmlTag.ReplaceAllString(l.Text, ""))
+}
+
+var htmlTag = regexp.MustCompile(`<[^>]*>`)
+
+// FetchStage describes the steps of the stage with id stageId.
+func (c *Client) FetchStage(ctx context.Context, build string, stageId string) (*StageDescription, error) {
+	stage := new(StageDescription)
+	err := c.getJson(ctx, stageDescribeUrl(c.server.JobBaseUrl, build, stageId), stage)
+	if err != nil {
+		return nil, err
+	}
+	return stage, nil
+}
+
+// FetchNodeLog fetches the log of the step with id nodeId.
+func (c *Client) FetchNodeLog(ctx context.Context, build string, nodeId string) (*NodeLog, error) {
+	nodeLog := new(NodeLog)
+	err := c.getJson(ctx, nodeLogUrl(c.server.JobBaseUrl, build, nodeId), nodeLog)
+	if err != nil {
+		return nil, err
+	}
+	return nodeLog, nil
+}
+
+// FetchNodeConsole fetches the whole log of the step with id nodeId from
+// its own console, for when the log from FetchNodeLog HasMore. With html,
+// it is the annotated HTML log. The chunk's MoreData is set while the step
+// is still writing to it.
+func (c *Client) FetchNodeConsole(ctx context.Context, build string, nodeId string, html bool) (LogChunk, error) {
+	req, err := c.newRequest(ctx, nodeConsoleUrl(c.server.JobBaseUrl, build, nodeId, html))
+	if err != nil {
+		return LogChunk{}, err
+	}
+	return c.fetchLog(req, 0)
+}
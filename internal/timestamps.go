@@ -0,0 +1,61 @@
+package jenkins
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// timestampFormat is the format the Timestamper plugin is asked for, as a
+// Java date pattern, and the same format as a Go layout. Without one, the
+// plugin gives the seconds since the build started instead.
+const (
+	timestampFormat = "yyyy-MM-dd HH:mm:ss.SSS Z"
+	timestampLayout = "2006-01-02 15:04:05.000 -0700"
+)
+
+// jobTimestampsUrl returns the URL of the Timestamper plugin's timestamps
+// for build, one per line of the log, from line startLine (counting from 1).
+// Each one is the date and time the line was written, in timestampFormat.
+func jobTimestampsUrl(jobUrl string, build string, startLine int) string {
+	return fmt.Sprintf("%s/timestamps/?time=%s&startLine=%d", buildUrl(jobUrl, build), url.QueryEscape(timestampFormat), startLine)
+}
+
+// FetchTimestamps fetches the time each line of the log of build was
+// written, as recorded by the Timestamper plugin, starting from line
+// startLine (counting from 0). There is one time per line, which is zero
+// for lines the plugin has no timestamp for. The error is ErrNotFound if the
+// plugin isn't installed.
+func (c *Client) FetchTimestamps(ctx context.Context, build string, startLine int) ([]time.Time, error) {
+	resp, err := c.get(ctx, jobTimestampsUrl(c.server.JobBaseUrl, build, startLine+1))
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		_ = Body.Close()
+	}(resp.Body)
+
+	var times []time.Time
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			// Keeps the times lined up with the lines of the log
+			times = append(times, time.Time{})
+			continue
+		}
+		t, err := time.Parse(timestampLayout, line)
+		if err != nil {
+			return nil, malformedError(resp, fmt.Errorf("invalid timestamp %q: %w", line, err))
+		}
+		times = append(times, t)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, transportError(resp.Request, err)
+	}
+	return times, nil
+}
@@ -0,0 +1,56 @@
+package jenkins
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// TLSOptions configures how the client verifies Jenkins and identifies
+// itself to it over https.
+type TLSOptions struct {
+	// CAFile is a PEM bundle of certificate authorities to trust, on top of
+	// the system's
+	CAFile string
+	// CertFile and KeyFile are the PEM client certificate and its private key,
+	// for servers that require mutual TLS
+	CertFile string
+	KeyFile  string
+	// InsecureSkipVerify accepts any certificate the server presents
+	InsecureSkipVerify bool
+}
+
+// config returns the TLS configuration for the options, or nil to use the
+// default one.
+func (o TLSOptions) config() (*tls.Config, error) {
+	if o == (TLSOptions{}) {
+		return nil, nil
+	}
+	c := &tls.Config{InsecureSkipVerify: o.InsecureSkipVerify}
+	if o.CAFile != "" {
+		pem, err := os.ReadFile(o.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("reading CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", o.CAFile)
+		}
+		c.RootCAs = pool
+	}
+	if (o.CertFile == "") != (o.KeyFile == "") {
+		return nil, fmt.Errorf("a client certificate needs both a certificate and a key file")
+	}
+	if o.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(o.CertFile, o.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("loading client certificate: %w", err)
+		}
+		c.Certificates = []tls.Certificate{cert}
+	}
+	return c, nil
+}
@@ -1,14 +1,25 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
+	"github.com/charmbracelet/bubbles/help"
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/spinner"
+	"github.com/charmbracelet/bubbles/textinput"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/charmbracelet/lipgloss"
 	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+	"github.com/jashort/jenkins-log-streamer/internal/console"
 	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
 	"github.com/urfave/cli/v2"
 	"log"
+	"net/url"
 	"os"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -25,26 +36,97 @@ var (
 		b.Left = "┤"
 		return titleStyle.Copy().BorderStyle(b)
 	}()
+
+	errorStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("9"))
+
+	noticeStyle = lipgloss.NewStyle().Faint(true)
 )
 
 type model struct {
 	// Program state
-	server   jenkins.ServerInfo
+	client   *jenkins.Client
+	ctx      context.Context
 	ready    bool
+	width    int
 	viewport jlsviewport.Model
-	content  string
-	debug    bool
+	stages   stagePanel
+	// keys and viewportKeys are the keys of the program and the viewport,
+	// which may be changed in the config file
+	keys         keyMap
+	viewportKeys jlsviewport.KeyMap
+	// help shows the main keys in the footer, or all of them over the log
+	// with showHelp
+	help     help.Model
+	showHelp bool
+	// stageFilter is set while the log is limited to a single stage
+	stageFilter *stageFilter
+	// showHistory switches from the log to the build history screen
+	showHistory bool
+	history     history
+	// prompting shows input in the footer to type the text for prompt
+	prompting bool
+	prompt    promptKind
+	input     textinput.Model
+	content   string
+	debug     bool
+	// output is the terminal the program renders to, and copies to the
+	// clipboard through
+	output *os.File
 	// Jenkins job state
-	jobStartTime    int64
-	jobName         string
-	jobStatus       string
-	err             error
+	// build selects the build to show. Following lastBuild switches to each
+	// new build, anything else is pinned to the build it first resolves to.
+	build        string
+	jobStartTime int64
+	jobName      string
+	jobStatus    string
+	err          error
+	// notice is shown in the footer until the next key press
+	notice          string
 	job             jenkins.JobStatus
 	secondsLeft     int
+	conn            connection
 	currentBuildNum int
 	logPosition     int64
 	moreData        bool
 	logChunks       []logChunk
+	// html fetches the annotated log, converted by converter. The plain log
+	// has its console notes removed by notes and its colors carried over
+	// from line to line by colors instead, and its Pipeline sections found
+	// by sections. Either way, traces finds the stack traces in the log.
+	html      bool
+	annotator string
+	converter *console.HtmlConverter
+	notes     *console.NoteStripper
+	colors    *console.ColorCarrier
+	sections  *console.SectionParser
+	traces    *console.TraceFinder
+	// collapseStages collapses the section of each stage once it succeeds,
+	// once, remembering the first line of the sections in collapsed
+	collapseStages bool
+	collapsed      map[int]bool
+	// noColor starts the viewport with colors hidden
+	noColor bool
+	// timestamps holds the Timestamper plugin's timestamp of each line of the
+	// log fetched so far, shown in the viewport's gutter unless the mode is
+	// off
+	timestampMode     timestampMode
+	timestamps        []time.Time
+	timestampsPending bool
+	// timestampFailures counts the failed fetches of timestamps in a row,
+	// to back off before retrying
+	timestampFailures int
+	// arrivals holds the time each line of the log arrived, shown instead of
+	// the timestamps when the server has no Timestamper plugin
+	arrivals      []time.Time
+	noTimestamper bool
+	// markers mark errors and warnings in the log. With firstError, the log
+	// of a failed build is shown from the first error once it's complete.
+	markers         []jlsviewport.Marker
+	firstError      bool
+	shownFirstError bool
+	// logCtx is cancelled when the build changes so stale log requests stop
+	logCtx    context.Context
+	cancelLog context.CancelFunc
 }
 
 func (m model) headerView() string {
@@ -55,16 +137,60 @@ func (m model) headerView() string {
 	startTime := time.UnixMilli(m.jobStartTime).Format(time.RFC822)
 	fmtLine := "%s %s (Started %s)"
 	//Log Position: %d   More data: %t    Refresh in: %d`
+	text := fmt.Sprintf(fmtLine, m.jobName, statusLine, startTime)
+	if m.stageFilter != nil {
+		text += " Stage: " + m.stageFilter.stage.Name
+	}
+	text += problemCounts(m.viewport.MarkedLines())
+	if filter := m.viewport.Filter(); filter.Active() {
+		text += fmt.Sprintf(" Filter: %s (%d of %d lines)", filterDescription(filter), m.viewport.ShownLineCount(), m.viewport.TotalLineCount())
+	}
 
-	title := titleStyle.Render(fmt.Sprintf(fmtLine, m.jobName, statusLine, startTime))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(title)))
+	title := titleStyle.Render(text)
+	if m.showHistory {
+		title = titleStyle.Render(fmt.Sprintf("Build history: %s to open, %s to follow the latest build, %s to go back",
+			m.keys.OpenBuild.Help().Key, m.keys.FollowLatest.Help().Key, m.keys.Back.Help().Key))
+	}
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(title)))
 	return lipgloss.JoinHorizontal(lipgloss.Center, title, line)
 }
 
 func (m model) footerView() string {
-	info := infoStyle.Render(fmt.Sprintf("Refresh in %d        %3.f%%", m.secondsLeft, m.viewport.ScrollPercent()*100))
-	line := strings.Repeat("─", max(0, m.viewport.Width-lipgloss.Width(info)))
-	return lipgloss.JoinHorizontal(lipgloss.Center, line, info)
+	position := fmt.Sprintf("%3.f%%", m.viewport.ScrollPercent()*100)
+	if m.showHistory {
+		position = fmt.Sprintf("%d/%d", m.history.table.Cursor()+1, len(m.history.builds))
+	}
+	if m.viewport.SearchPattern() != nil && !m.showHistory {
+		current, total := m.viewport.SearchMatches()
+		if current > 0 {
+			position = fmt.Sprintf("match %d of %d  %s", current, total, position)
+		} else {
+			position = fmt.Sprintf("%d matches  %s", total, position)
+		}
+	}
+	info := infoStyle.Render(fmt.Sprintf("%s        %s", m.conn.status(m.secondsLeft), position))
+	errText := ""
+	if m.prompting {
+		errText = m.input.View()
+	} else if m.err != nil {
+		errText = errorStyle.Copy().
+			MaxWidth(max(0, m.width-lipgloss.Width(info)-1)).
+			Render(m.err.Error())
+	} else if m.viewport.Selecting() {
+		errText = noticeStyle.Copy().
+			MaxWidth(max(0, m.width-lipgloss.Width(info)-1)).
+			Render(fmt.Sprintf("%s selected, %s to copy, %s to cancel", plural(m.viewport.SelectedLines(), "line"), m.viewport.KeyMap.Copy.Help().Key, m.keys.Back.Help().Key))
+	} else if m.notice != "" {
+		errText = noticeStyle.Copy().
+			MaxWidth(max(0, m.width-lipgloss.Width(info)-1)).
+			Render(m.notice)
+	} else if !m.showHistory && !m.showHelp {
+		h := m.help
+		h.Width = max(0, m.width-lipgloss.Width(info)-1)
+		errText = h.View(keyHelp{app: m.keys, log: m.viewport.KeyMap})
+	}
+	line := strings.Repeat("─", max(0, m.width-lipgloss.Width(info)-lipgloss.Width(errText)))
+	return lipgloss.JoinHorizontal(lipgloss.Center, errText, line, info)
 }
 
 type logChunk struct {
@@ -73,11 +199,15 @@ type logChunk struct {
 }
 
 type jobStatusMsg struct {
+	// build is the build number or permalink that was requested
+	build      string
 	name       string
 	startTime  int64
 	buildNum   int
 	inProgress bool
 	result     string
+	// pipeline is set for Pipeline runs, whose stages are fetched next
+	pipeline bool
 }
 
 type jobLogMsg struct {
@@ -86,6 +216,7 @@ type jobLogMsg struct {
 	moreData    bool
 	newPosition int64
 	buildNum    int
+	annotator   string
 }
 
 type errMsg struct{ err error }
@@ -93,7 +224,7 @@ type errMsg struct{ err error }
 func (e errMsg) Error() string { return e.err.Error() }
 
 func (m model) Init() tea.Cmd {
-	return tea.Batch(tick(), updateStatus(m.server), tea.EnterAltScreen)
+	return tea.Batch(tick(), updateStatus(m.ctx, m.client, m.build), tea.EnterAltScreen)
 }
 
 func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
@@ -106,9 +237,78 @@ func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 	}
 	switch msg := message.(type) {
 	case tea.KeyMsg:
-		switch msg.String() {
-		case "q", "esc", "ctrl+c":
+		m.notice = ""
+		if m.prompting {
+			return m.updatePrompt(msg)
+		}
+		if m.showHistory {
+			return m.updateHistory(msg)
+		}
+		if m.stages.focused {
+			return m.updateStagePanel(msg)
+		}
+		if m.showHelp {
+			if key.Matches(msg, m.keys.ForceQuit) {
+				return m, tea.Quit
+			}
+			// Any key closes the help
+			m.showHelp = false
+			return m, nil
+		}
+		switch {
+		case key.Matches(msg, m.keys.Back):
+			if m.viewport.Selecting() {
+				m.viewport.ClearSelection()
+				return m, nil
+			}
+			if m.viewport.SearchPattern() != nil {
+				m.viewport.Search(nil, false)
+				return m, nil
+			}
+			if m.viewport.Filter().Active() {
+				m.viewport.SetFilter(jlsviewport.Filter{})
+				return m, nil
+			}
+			if m.stageFilter != nil {
+				m.clearStageFilter()
+				return m, nil
+			}
 			return m, tea.Quit
+		case key.Matches(msg, m.keys.Quit, m.keys.ForceQuit):
+			return m, tea.Quit
+		case key.Matches(msg, m.keys.Help):
+			m.showHelp = true
+			return m, nil
+		case key.Matches(msg, m.keys.FocusStages):
+			if m.stages.visible() {
+				m.stages.focused = true
+			}
+			return m, nil
+		case key.Matches(msg, m.keys.History):
+			m.showHistory = true
+			return m, fetchBuilds(m.ctx, m.client)
+		case key.Matches(msg, m.keys.ToggleStages):
+			m.stages.hidden = !m.stages.hidden
+			m.layout()
+			return m, nil
+		case key.Matches(msg, m.keys.Search):
+			return m, m.startPrompt(searchForwardPrompt)
+		case key.Matches(msg, m.keys.SearchBackward):
+			return m, m.startPrompt(searchBackwardPrompt)
+		case key.Matches(msg, m.keys.Filter):
+			return m, m.startPrompt(filterPrompt)
+		case key.Matches(msg, m.keys.Command):
+			return m, m.startPrompt(commandPrompt)
+		case key.Matches(msg, m.keys.Save):
+			cmd = m.startPrompt(commandPrompt)
+			m.input.SetValue("save ")
+			m.input.CursorEnd()
+			return m, cmd
+		case key.Matches(msg, m.keys.Timestamps):
+			m.timestampMode = m.timestampMode.next()
+			m.updateGutter()
+			cmd = m.updateTimestamps()
+			return m, cmd
 		}
 
 	case tea.WindowSizeMsg:
@@ -116,21 +316,80 @@ func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 		footerHeight := lipgloss.Height(m.footerView())
 		verticalMarginHeight := headerHeight + footerHeight
 
+		m.width = msg.Width
 		if !m.ready {
 			m.viewport = jlsviewport.New(msg.Width, msg.Height-verticalMarginHeight)
+			m.viewport.KeyMap = m.viewportKeys
+			m.viewport.NoColor = m.noColor
+			m.viewport.SetMarkers(m.markers)
+			m.updateGutter()
 			m.viewport.YPosition = headerHeight
 			m.viewport.SetContent(m.content)
 			m.ready = true
 			m.viewport.YPosition = headerHeight + 1
 		} else {
-			m.viewport.Width = msg.Width
 			m.viewport.Height = msg.Height - verticalMarginHeight
 		}
+		m.layout()
+		m.history.setSize(msg.Width, m.viewport.Height)
 
 		m.viewport, cmd = m.viewport.Update(msg)
 		cmds = append(cmds, cmd)
 
+	case tea.MouseMsg:
+		if m.showHistory {
+			return m, nil
+		}
+		// The viewport takes positions from its top
+		msg.Y -= lipgloss.Height(m.headerView())
+		m.viewport, cmd = m.viewport.Update(msg)
+		return m, cmd
+
+	case jlsviewport.CopyMsg:
+		return m, copyToClipboard(m.output, msg.Text, msg.Lines)
+
+	case copiedMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Copying failed: %s", msg.err)
+		} else {
+			m.notice = fmt.Sprintf("Copied %s", plural(msg.lines, "line"))
+		}
+		return m, nil
+
+	case savedMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Saving failed: %s", msg.err)
+		} else {
+			m.notice = fmt.Sprintf("Saved %s to %s", plural(msg.lines, "line"), msg.path)
+		}
+		return m, nil
+
+	case errMsg:
+		// Keep showing what we have and back off before the next attempt.
+		// The log resumes from logPosition once Jenkins is reachable again.
+		m.err = msg.err
+		if seconds, ok := m.conn.failed(time.Now()); ok {
+			m.secondsLeft = seconds
+		}
+		return m, nil
+
+	case buildsMsg:
+		if msg.err != nil {
+			m.notice = fmt.Sprintf("Loading the build history failed: %s", msg.err)
+			return m, nil
+		}
+		m.history.setBuilds(msg.builds)
+		return m, nil
+
+	case spinner.TickMsg:
+		return m, m.stages.update(msg)
+
 	case jobStatusMsg:
+		if msg.build != m.build {
+			// Requested before a different build was selected
+			return m, nil
+		}
+		m.err = nil
 		m.jobStartTime = msg.startTime
 		m.jobName = msg.name
 		if msg.result != "" {
@@ -141,46 +400,212 @@ func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 			}
 		}
 
+		if m.build != jenkins.LastBuild {
+			m.build = strconv.Itoa(msg.buildNum)
+		}
+
 		// If the latest build number has changed, clear the log
 		if m.currentBuildNum != msg.buildNum {
+			if m.cancelLog != nil {
+				m.cancelLog()
+			}
+			m.logCtx, m.cancelLog = context.WithCancel(m.ctx)
 			m.logChunks = nil
 			m.logPosition = 0
 			m.currentBuildNum = msg.buildNum
 			m.moreData = true
 			m.content = ""
+			m.stages.setStages(nil)
+			m.stages.selected = ""
+			m.stageFilter = nil
+			m.annotator = ""
+			m.converter = console.NewHtmlConverter(m.client.BuildUrl(strconv.Itoa(msg.buildNum)))
+			m.notes = &console.NoteStripper{}
+			m.colors = &console.ColorCarrier{}
+			m.sections = &console.SectionParser{}
+			m.traces = &console.TraceFinder{}
+			m.collapsed = map[int]bool{}
+			m.viewport.SetFolds(nil)
+			m.timestamps = nil
+			m.timestampsPending = false
+			m.timestampFailures = 0
+			m.arrivals = nil
+			m.shownFirstError = false
+			m.updateGutter()
+		}
+		if msg.pipeline {
+			cmds = append(cmds, fetchPipeline(m.logCtx, m.client, m.currentBuildNum))
 		}
+		m.layout()
 
 		if m.moreData {
-			return m, updateLog(m.server, m.logPosition, m.currentBuildNum)
+			// Wait for the log to arrive before calling the connection healthy
+			cmds = append(cmds, m.updateLog(m.logPosition))
 		} else {
+			m.conn.succeeded(time.Now())
+		}
+		return m, tea.Batch(cmds...)
+
+	case pipelineMsg:
+		if msg.buildNum != m.currentBuildNum {
 			return m, nil
 		}
+		if msg.err != nil {
+			// Stages are optional, the log is still shown without the
+			// workflow API
+			if m.debug {
+				log.Printf("Fetching the stages of build %d failed: %s", msg.buildNum, msg.err)
+			}
+			return m, nil
+		}
+		cmds = append(cmds, m.stages.setStages(msg.run.Stages))
+		if m.stageFilter == nil {
+			m.updateFolds()
+		}
+		m.layout()
+		if m.stageFilter != nil {
+			if stage, ok := m.stages.find(m.stageFilter.stage.Id); ok {
+				m.stageFilter.stage = stage
+			}
+			if m.stageFilter.needsUpdate(m.stageFilter.stage.Status) {
+				cmds = append(cmds, m.updateStageLog())
+			}
+		}
+		return m, tea.Batch(cmds...)
 
 	case jobLogMsg:
-		if msg.buildNum == m.currentBuildNum {
-			if len(msg.body) != 0 {
-				shouldScroll := m.viewport.AtBottom()
-				lines := strings.Split(msg.body, "\n")
+		// A chunk that doesn't start at the current position is a duplicate
+		// request that was overtaken, for example by a retry
+		if msg.buildNum == m.currentBuildNum && msg.start == m.logPosition {
+			m.err = nil
+			m.conn.succeeded(time.Now())
+			body := msg.body
+			if m.html {
+				body = m.converter.Convert(body)
+				if !msg.moreData {
+					body += m.converter.Flush()
+				}
+				m.annotator = msg.annotator
+			} else {
+				body = m.notes.Strip(body)
+				if !msg.moreData {
+					// Nothing held back will be completed by another chunk
+					body += m.notes.Flush()
+				}
+				body = m.colors.Carry(body)
+				if !msg.moreData {
+					body += m.colors.Flush()
+				}
+				m.sections.Parse(body)
+			}
+			m.traces.Parse(body)
+			if len(body) != 0 {
+				lines := strings.Split(body, "\n")
 				chunk := logChunk{
 					lineCount: len(lines),
 					lines:     lines,
 				}
 				m.logChunks = append(m.logChunks, chunk)
-				m.content += msg.body
-				m.viewport.SetContent(m.content)
-				if shouldScroll {
-					m.viewport.GotoBottom()
+				m.arrivals = addArrivals(m.arrivals, m.content, body, time.Now())
+				m.content += body
+				if m.noTimestamper {
+					m.updateGutter()
+				}
+				if m.stageFilter == nil {
+					m.showContent(m.content)
+					m.updateFolds()
 				}
 			}
 
 			m.logPosition = msg.newPosition
 			m.moreData = msg.moreData
+			if !msg.moreData && m.firstError && !m.shownFirstError && m.jobStatus == jenkins.ResultFailure && m.stageFilter == nil {
+				m.shownFirstError = true
+				if line := m.viewport.FirstMark(errorMarker); line >= 0 {
+					m.viewport.ShowLine(line)
+				}
+			}
 			// moreData may mean "the log is finished, but you're not at the last chunk" or it
 			// may mean "the job is still running but there's no new data in the log". In the second
 			// case, we don't want to immediately try to get more data, wait for updating the job
 			// status to trigger it
+			cmd = m.updateTimestamps()
 			if msg.moreData && len(msg.body) > 0 {
-				return m, updateLog(m.server, msg.newPosition, msg.buildNum)
+				return m, tea.Batch(m.updateLog(msg.newPosition), cmd)
+			}
+			return m, cmd
+		}
+		return m, nil
+
+	case timestampsMsg:
+		if msg.buildNum != m.currentBuildNum {
+			return m, nil
+		}
+		m.timestampsPending = false
+		if errors.Is(msg.err, jenkins.ErrNotFound) {
+			m.noTimestamper = true
+			m.updateGutter()
+			m.notice = "Timestamper plugin not found, showing when lines arrived instead"
+			return m, nil
+		}
+		if msg.err != nil {
+			// Nothing else is fetched until the retry, which may be the
+			// only one once the log is complete
+			m.timestampFailures++
+			delay := backoff(m.timestampFailures)
+			m.notice = fmt.Sprintf("Loading timestamps failed: %s, retrying in %ds", msg.err, int(delay.Seconds()))
+			m.timestampsPending = true
+			return m, retryTimestamps(msg.buildNum, delay)
+		}
+		m.timestampFailures = 0
+		if msg.start != len(m.timestamps) || len(msg.times) == 0 {
+			// Tried again after the next chunk of the log
+			return m, nil
+		}
+		m.timestamps = append(m.timestamps, msg.times...)
+		m.updateGutter()
+		cmd = m.updateTimestamps()
+		return m, cmd
+
+	case retryTimestampsMsg:
+		if msg.buildNum != m.currentBuildNum {
+			return m, nil
+		}
+		m.timestampsPending = false
+		cmd = m.updateTimestamps()
+		return m, cmd
+
+	case stageLogMsg:
+		f := m.stageFilter
+		if f == nil || msg.buildNum != m.currentBuildNum || msg.stageId != f.stage.Id {
+			return m, nil
+		}
+		if msg.err != nil {
+			// Tried again when the stages are next fetched
+			m.notice = fmt.Sprintf("Loading the log of stage %s failed: %s", f.stage.Name, msg.err)
+			return m, nil
+		}
+		f.content = msg.content
+		f.nodeLogs = msg.nodeLogs
+		f.fetchedStatus = msg.status
+		traces := &console.TraceFinder{}
+		traces.Parse(f.content)
+		if f.loaded {
+			m.showContent(f.content)
+			atBottom := m.viewport.AtBottom()
+			m.viewport.SetFolds(logFolds(nil, traces.Traces))
+			if atBottom {
+				m.viewport.GotoBottom()
+			}
+		} else {
+			// Start at the top of finished stages and follow running ones
+			f.loaded = true
+			m.viewport.SetContent(f.content)
+			m.viewport.SetFolds(logFolds(nil, traces.Traces))
+			if msg.status == jenkins.StageInProgress {
+				m.viewport.GotoBottom()
+			} else {
+				m.viewport.GotoTop()
 			}
 		}
 		return m, nil
@@ -188,52 +613,243 @@ func (m model) Update(message tea.Msg) (tea.Model, tea.Cmd) {
 	case tickMsg:
 		m.secondsLeft--
 		if m.secondsLeft <= 0 {
-			m.secondsLeft = 5
-			return m, tea.Batch(updateStatus(m.server), tick())
+			m.secondsLeft = refreshSeconds
+			m.conn.poll()
+			if m.showHistory {
+				return m, tea.Batch(updateStatus(m.ctx, m.client, m.build), fetchBuilds(m.ctx, m.client), tick())
+			}
+			return m, tea.Batch(updateStatus(m.ctx, m.client, m.build), tick())
 		}
 		return m, tick()
 	}
 
 	m.viewport, cmd = m.viewport.Update(message)
 	cmds = append(cmds, cmd)
+	if m.prompting {
+		m.input, cmd = m.input.Update(message)
+		cmds = append(cmds, cmd)
+	}
 
 	return m, tea.Batch(cmds...)
 }
 
+// layout sizes the viewport to leave room for the stage panel.
+func (m *model) layout() {
+	m.viewport.Width = m.width - m.stages.width(m.width)
+}
+
+// showContent replaces the log shown in the viewport, which keeps following
+// the end of the log if it was scrolled to the bottom.
+func (m *model) showContent(content string) {
+	shouldScroll := m.viewport.AtBottom()
+	m.viewport.SetContent(content)
+	if shouldScroll {
+		m.viewport.GotoBottom()
+	}
+}
+
+// updateTimestamps fetches the timestamps of new lines of the log, if they
+// are shown.
+func (m *model) updateTimestamps() tea.Cmd {
+	if m.timestampMode == timestampsOff || m.noTimestamper || m.timestampsPending || len(m.timestamps) >= strings.Count(m.content, "\n") {
+		return nil
+	}
+	m.timestampsPending = true
+	return fetchTimestamps(m.logCtx, m.client, m.currentBuildNum, len(m.timestamps))
+}
+
+// updateGutter shows the timestamps next to the log, except for the log of
+// a single stage, which they don't line up with.
+func (m *model) updateGutter() {
+	if m.timestampMode == timestampsOff || m.stageFilter != nil {
+		m.viewport.Gutter = nil
+		return
+	}
+	times := m.timestamps
+	if m.noTimestamper {
+		times = m.arrivals
+	}
+	m.viewport.Gutter = timestampGutter(m.timestampMode, times, time.UnixMilli(m.jobStartTime))
+	m.viewport.GutterWidth = timestampsWidth
+}
+
+// updateStageLog fetches the log of the stage the log is filtered to.
+func (m model) updateStageLog() tea.Cmd {
+	return fetchStageLog(m.logCtx, m.client, m.currentBuildNum, m.stageFilter.stage, m.stageFilter.nodeLogs, m.html)
+}
+
+// clearStageFilter goes back to showing the whole log.
+func (m *model) clearStageFilter() {
+	m.stageFilter = nil
+	m.stages.selected = ""
+	m.updateGutter()
+	m.viewport.SetContent(m.content)
+	m.viewport.GotoBottom()
+	m.updateFolds()
+}
+
+// updateFolds lets the viewport collapse the Pipeline sections and stack
+// traces of the log, and with collapseStages, collapses the stages that
+// succeeded so the running one has the screen.
+func (m *model) updateFolds() {
+	sections := m.sections.Sections
+	if m.html {
+		sections = m.converter.Sections
+	}
+	atBottom := m.viewport.AtBottom()
+	m.viewport.SetFolds(logFolds(sections, m.traces.Traces))
+	if m.collapseStages {
+		for _, s := range sections {
+			if s.End < 0 || m.collapsed[s.Start] || !m.stages.succeeded(s.Label) {
+				continue
+			}
+			m.collapsed[s.Start] = true
+			m.viewport.SetFoldCollapsed(s.Start, true)
+		}
+	}
+	if atBottom {
+		m.viewport.GotoBottom()
+	}
+}
+
+// logFolds lets the viewport collapse Pipeline sections and stack traces.
+// Stack traces start collapsed, leaving the lines that describe them.
+func logFolds(sections []console.Section, traces []console.Trace) []jlsviewport.Fold {
+	folds := make([]jlsviewport.Fold, 0, len(sections)+len(traces))
+	for _, s := range sections {
+		folds = append(folds, jlsviewport.Fold{Start: s.Start, End: s.End})
+	}
+	for _, t := range traces {
+		if t.End > t.Start {
+			folds = append(folds, jlsviewport.Fold{Start: t.Start, End: t.End, Collapsed: true})
+		}
+	}
+	slices.SortStableFunc(folds, func(a, b jlsviewport.Fold) int {
+		return cmp.Compare(a.Start, b.Start)
+	})
+	return folds
+}
+
+// updateStagePanel handles keys while the stage panel has focus.
+func (m model) updateStagePanel(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Quit, m.keys.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.Back, m.keys.FocusStages):
+		m.stages.focused = false
+	case key.Matches(msg, m.viewport.KeyMap.Up):
+		m.stages.moveCursor(-1)
+	case key.Matches(msg, m.viewport.KeyMap.Down):
+		m.stages.moveCursor(1)
+	case key.Matches(msg, m.keys.ShowStage):
+		stage, ok := m.stages.stage()
+		if !ok {
+			return m, nil
+		}
+		m.stages.focused = false
+		if stage.Id == m.stages.selected {
+			m.clearStageFilter()
+			return m, nil
+		}
+		m.stages.selected = stage.Id
+		m.stageFilter = &stageFilter{stage: stage}
+		m.viewport.SetContent("")
+		m.viewport.SetFolds(nil)
+		m.updateGutter()
+		return m, m.updateStageLog()
+	}
+	return m, nil
+}
+
+// updateHistory handles keys while the build history is shown.
+func (m model) updateHistory(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.Quit, m.keys.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.Back, m.keys.History):
+		m.showHistory = false
+		return m, nil
+	case key.Matches(msg, m.keys.OpenBuild):
+		if b, ok := m.history.selected(); ok {
+			m.showHistory = false
+			m.build = strconv.Itoa(b.Number)
+			return m, updateStatus(m.ctx, m.client, m.build)
+		}
+		return m, nil
+	case key.Matches(msg, m.keys.FollowLatest):
+		m.showHistory = false
+		m.build = jenkins.LastBuild
+		return m, updateStatus(m.ctx, m.client, m.build)
+	}
+	var cmd tea.Cmd
+	m.history.table, cmd = m.history.table.Update(msg)
+	return m, cmd
+}
+
 func (m model) View() string {
 	if !m.ready {
 		return "\n  Initializing..."
 	}
+	if m.showHistory {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.history.table.View(), m.footerView())
+	}
 
-	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.viewport.View(), m.footerView())
+	if m.showHelp {
+		return fmt.Sprintf("%s\n%s\n%s", m.headerView(), m.helpView(), m.footerView())
+	}
+
+	body := m.viewport.View()
+	if m.stages.visible() {
+		body = joinColumns(body, m.viewport.Width, m.stages.View(m.stages.width(m.width), m.viewport.Height))
+	}
+	return fmt.Sprintf("%s\n%s\n%s", m.headerView(), body, m.footerView())
 }
 
-func updateStatus(server jenkins.ServerInfo) tea.Cmd {
+func updateStatus(ctx context.Context, client *jenkins.Client, build string) tea.Cmd {
 	return func() tea.Msg {
-		response, err := jenkins.FetchJobStatus(server)
+		response, err := client.FetchJobStatus(ctx, build)
 		if err != nil {
 			return errMsg{err}
 		}
 		x := jobStatusMsg{
+			build:      build,
 			name:       response.FullDisplayName,
 			startTime:  response.Timestamp,
 			buildNum:   response.Number,
 			inProgress: response.InProgress,
 			result:     response.Result,
+			pipeline:   response.IsPipeline(),
 		}
 		return tea.Msg(x)
 	}
 }
 
-func updateLog(server jenkins.ServerInfo, start int64, jobNumber int) tea.Cmd {
+// updateLog fetches the log of the current build from start.
+func (m model) updateLog(start int64) tea.Cmd {
+	ctx, client, jobNumber := m.logCtx, m.client, m.currentBuildNum
+	html, annotator := m.html, m.annotator
 	return func() tea.Msg {
-		data := jenkins.FetchLog(server, start)
+		var data jenkins.LogChunk
+		var err error
+		if html {
+			data, err = client.FetchHtmlLog(ctx, strconv.Itoa(jobNumber), start, annotator)
+		} else {
+			data, err = client.FetchLog(ctx, strconv.Itoa(jobNumber), start)
+		}
+		if errors.Is(err, context.Canceled) {
+			// The build changed while this request was in flight
+			return nil
+		}
+		if err != nil {
+			return errMsg{err}
+		}
 		x := jobLogMsg{
 			body:        data.Body,
 			start:       start,
 			newPosition: data.NewPosition,
 			moreData:    data.MoreData,
 			buildNum:    jobNumber,
+			annotator:   data.Annotator,
 		}
 		return tea.Msg(x)
 	}
@@ -249,13 +865,24 @@ type tickMsg time.Time
 
 func main() {
 	app := &cli.App{
-		Usage: "Stream console log from a Jenkins job",
+		Usage:     "Stream console log from a Jenkins job",
+		ArgsUsage: "[job]",
 		Flags: []cli.Flag{
 			&cli.StringFlag{
 				Name:  "url",
 				Value: "",
 				Usage: "Jenkins job `Url`",
 			},
+			&cli.StringFlag{
+				Name:  "server",
+				Value: "",
+				Usage: "Log in to the job with the credentials of the `name`d server from the config file",
+			},
+			&cli.StringFlag{
+				Name:  "build",
+				Value: jenkins.LastBuild,
+				Usage: "Build `number` or permalink (" + strings.Join(jenkins.Permalinks, ", ") + ")",
+			},
 			&cli.StringFlag{
 				Name:    "user",
 				Value:   "",
@@ -263,10 +890,92 @@ func main() {
 				EnvVars: []string{"JENKINS_USER"},
 			},
 			&cli.StringFlag{
-				Name:    "token",
+				// JENKINS_TOKEN is read by findCredentials, as --token-file
+				// and --token-command win over it
+				Name:  "token",
+				Value: "",
+				Usage: "Jenkins API token, or set $JENKINS_TOKEN",
+			},
+			&cli.StringFlag{
+				Name:  "token-file",
+				Value: "",
+				Usage: "Read the Jenkins API token from `file`",
+			},
+			&cli.StringFlag{
+				Name:  "token-command",
+				Value: "",
+				Usage: "Run `command` with the shell and read the Jenkins API token from its output, like a password manager",
+			},
+			&cli.StringFlag{
+				Name:  "auth",
+				Value: "",
+				Usage: "Send the token with `scheme` basic, with the user, bearer, or cookie, as session cookies like name=value (default: \"basic\")",
+			},
+			&cli.GenericFlag{
+				Name:  "header",
+				Value: &repeatedFlag{},
+				Usage: "Send the header `\"Name: value\"` with every request, may be repeated",
+			},
+			&cli.StringFlag{
+				Name:  "proxy",
+				Value: "",
+				Usage: "Connect to Jenkins through the proxy at `URL`, or directly with none, instead of the proxy from the environment",
+			},
+			&cli.StringFlag{
+				Name:  "ca-file",
+				Value: "",
+				Usage: "Trust the certificate authorities in the PEM `file` as well as the system's",
+			},
+			&cli.StringFlag{
+				Name:  "cert-file",
+				Value: "",
+				Usage: "Identify with the PEM client certificate in `file`, for servers that require one",
+			},
+			&cli.StringFlag{
+				Name:  "key-file",
+				Value: "",
+				Usage: "Read the private key of --cert-file from `file`",
+			},
+			&cli.BoolFlag{
+				Name:  "insecure",
+				Usage: "Don't verify the certificate of the Jenkins server",
+			},
+			&cli.BoolFlag{
+				Name:  "html",
+				Usage: "Show links, styled Pipeline step markers and collapsible Pipeline sections from the annotated log",
+			},
+			&cli.BoolFlag{
+				Name:  "no-color",
+				Usage: "Hide colors in the log, press c to show them",
+			},
+			&cli.StringFlag{
+				Name:  "timestamps",
+				Value: "off",
+				Usage: "Show when each line was written as the time of day (clock), time since the build started (elapsed) or time since the previous line (delta), press t to switch. Without the Timestamper plugin, shows when lines arrived",
+			},
+			&cli.GenericFlag{
+				Name:  "error-pattern",
+				Value: &repeatedFlag{},
+				Usage: "Regular expression for lines to mark as errors, may be repeated to replace the built in patterns",
+			},
+			&cli.GenericFlag{
+				Name:  "warning-pattern",
+				Value: &repeatedFlag{},
+				Usage: "Regular expression for lines to mark as warnings, may be repeated to replace the built in patterns",
+			},
+			&cli.BoolFlag{
+				Name:  "first-error",
+				Usage: "Show the log of a failed build from the first error",
+			},
+			&cli.BoolFlag{
+				Name:  "collapse-stages",
+				Usage: "Collapse each Pipeline stage once it succeeds, so the running stage fills the screen",
+			},
+			&cli.StringFlag{
+				Name:    "config",
 				Value:   "",
-				Usage:   "Jenkins API token",
-				EnvVars: []string{"JENKINS_TOKEN"},
+				Usage:   "Read settings such as keys from `file` instead of jenkins-log-streamer/config.toml in the user config directory",
+				EnvVars: []string{"JLS_CONFIG"},
 			},
 			&cli.StringFlag{
 				Name:    "log",
@@ -283,17 +992,133 @@ func main() {
 				}
 				debugMode = true
 			}
+			configPath := cCtx.String("config")
+			explicitConfig := configPath != ""
+			if !explicitConfig {
+				configPath = defaultConfigPath()
+			}
+			cfg, err := loadConfig(configPath, explicitConfig)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			if cCtx.NArg() > 1 {
+				log.Fatal("Error: expected at most one job, got ", strings.Join(cCtx.Args().Slice(), " "))
+			}
+			jobUrl, serverConfig, err := resolveJob(cfg, cCtx.Args().First(), cCtx.String("url"), cCtx.String("server"))
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			creds, err := findCredentials(credentialOptions{
+				User:         cCtx.String("user"),
+				Token:        cCtx.String("token"),
+				TokenFile:    cCtx.String("token-file"),
+				TokenCommand: cCtx.String("token-command"),
+				TokenEnv:     os.Getenv("JENKINS_TOKEN"),
+			}, serverConfig, jobUrl)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			scheme := "basic"
+			if cCtx.String("auth") != "" {
+				scheme = cCtx.String("auth")
+			} else if serverConfig.Auth != "" {
+				scheme = serverConfig.Auth
+			}
+			auth, err := creds.auth(scheme)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			if debugMode {
+				log.Printf("Logging in to %s as %q with %s auth, token from %s", jobUrl, creds.user, scheme, creds.source)
+			}
+			headers, err := serverConfig.requestHeaders(*cCtx.Generic("header").(*repeatedFlag))
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			if cCtx.IsSet("cert-file") != cCtx.IsSet("key-file") {
+				log.Fatal("Error: --cert-file and --key-file must be used together")
+			}
 			server := jenkins.ServerInfo{
-				JobBaseUrl: cCtx.String("url"),
-				User:       cCtx.String("user"),
-				Token:      cCtx.String("token"),
+				JobBaseUrl: jobUrl,
+				Auth:       auth,
+				Headers:    headers,
+				Proxy:      serverConfig.Proxy,
+				TLS: serverConfig.tlsOptions(jenkins.TLSOptions{
+					CAFile:             cCtx.String("ca-file"),
+					CertFile:           cCtx.String("cert-file"),
+					KeyFile:            cCtx.String("key-file"),
+					InsecureSkipVerify: cCtx.Bool("insecure"),
+				}),
+			}
+			if proxy := cCtx.String("proxy"); proxy != "" {
+				server.Proxy = proxy
+			}
+			if debugMode {
+				if server.TLS != (jenkins.TLSOptions{}) {
+					log.Printf("TLS options: %+v", server.TLS)
+				}
+				if proxy, err := url.Parse(server.Proxy); err == nil && server.Proxy != "" {
+					log.Printf("Proxy: %s", proxy.Redacted())
+				}
+				// Only the names, as the values may be secrets
+				for _, name := range sortedKeys(server.Headers) {
+					log.Printf("Sending header %s", name)
+				}
+			}
+			client, err := jenkins.NewClient(server)
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			build, err := jenkins.ParseBuild(cCtx.String("build"))
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			timestamps, err := parseTimestampMode(cCtx.String("timestamps"))
+			if err != nil {
+				log.Fatal("Error: ", err)
+			}
+			errorPatterns, warningPatterns := defaultErrorPatterns, defaultWarningPatterns
+			if cCtx.IsSet("error-pattern") {
+				errorPatterns = *cCtx.Generic("error-pattern").(*repeatedFlag)
+			}
+			if cCtx.IsSet("warning-pattern") {
+				warningPatterns = *cCtx.Generic("warning-pattern").(*repeatedFlag)
+			}
+			markers, err := problemMarkers(errorPatterns, warningPatterns)
+			if err != nil {
+				log.Fatal("Error: ", err)
 			}
-			if server.JobBaseUrl == "" {
-				log.Fatal("Error: jenkins URL not specified. Use --url option")
+			keys, viewportKeys := defaultKeyMap(), jlsviewport.DefaultKeyMap()
+			if err := applyKeys(cfg.Keys, &keys, &viewportKeys); err != nil {
+				log.Fatal("Error: ", err)
 			}
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			output := os.Stdout
 			p := tea.NewProgram(
-				model{secondsLeft: 5, server: server, debug: debugMode},
+				model{
+					secondsLeft:    refreshSeconds,
+					client:         client,
+					ctx:            ctx,
+					output:         output,
+					build:          build,
+					html:           cCtx.Bool("html"),
+					noColor:        cCtx.Bool("no-color"),
+					timestampMode:  timestamps,
+					history:        newHistory(viewportKeys),
+					keys:           keys,
+					viewportKeys:   viewportKeys,
+					help:           help.New(),
+					input:          newPrompt(),
+					markers:        markers,
+					firstError:     cCtx.Bool("first-error"),
+					collapseStages: cCtx.Bool("collapse-stages"),
+					stages:         newStagePanel(),
+					debug:          debugMode,
+				},
+				tea.WithOutput(output),
 				tea.WithAltScreen(),
+				tea.WithMouseCellMotion(),
 			)
 			if _, err := p.Run(); err != nil {
 				log.Fatal(err)
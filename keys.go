@@ -0,0 +1,138 @@
+package main
+
+import (
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// keyMap defines the keybindings of the program, besides the ones the
+// viewport handles.
+type keyMap struct {
+	Quit           key.Binding
+	ForceQuit      key.Binding
+	Back           key.Binding
+	Help           key.Binding
+	Search         key.Binding
+	SearchBackward key.Binding
+	Filter         key.Binding
+	Command        key.Binding
+	Save           key.Binding
+	Timestamps     key.Binding
+	History        key.Binding
+	ToggleStages   key.Binding
+	FocusStages    key.Binding
+	ShowStage      key.Binding
+	OpenBuild      key.Binding
+	FollowLatest   key.Binding
+	PromptSubmit   key.Binding
+	PromptCancel   key.Binding
+}
+
+func defaultKeyMap() keyMap {
+	return keyMap{
+		Quit: key.NewBinding(
+			key.WithKeys("q"),
+			key.WithHelp("q", "quit"),
+		),
+		ForceQuit: key.NewBinding(
+			key.WithKeys("ctrl+c"),
+			key.WithHelp("ctrl+c", "quit, even from a prompt"),
+		),
+		Back: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "clear search/filter, back"),
+		),
+		// Not ?, which searches backward as in less
+		Help: key.NewBinding(
+			key.WithKeys("H", "f1"),
+			key.WithHelp("H", "help"),
+		),
+		Search: key.NewBinding(
+			key.WithKeys("/"),
+			key.WithHelp("/", "search"),
+		),
+		SearchBackward: key.NewBinding(
+			key.WithKeys("?"),
+			key.WithHelp("?", "search backward"),
+		),
+		Filter: key.NewBinding(
+			key.WithKeys("&"),
+			key.WithHelp("&", "filter lines"),
+		),
+		Command: key.NewBinding(
+			key.WithKeys(":"),
+			key.WithHelp(":", "command"),
+		),
+		Save: key.NewBinding(
+			key.WithKeys("ctrl+s"),
+			key.WithHelp("ctrl+s", "save log"),
+		),
+		Timestamps: key.NewBinding(
+			key.WithKeys("t"),
+			key.WithHelp("t", "switch timestamps"),
+		),
+		History: key.NewBinding(
+			key.WithKeys("h"),
+			key.WithHelp("h", "build history"),
+		),
+		ToggleStages: key.NewBinding(
+			key.WithKeys("s"),
+			key.WithHelp("s", "show/hide stages"),
+		),
+		FocusStages: key.NewBinding(
+			key.WithKeys("tab"),
+			key.WithHelp("tab", "select stage"),
+		),
+		ShowStage: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "show stage log"),
+		),
+		OpenBuild: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "open build"),
+		),
+		FollowLatest: key.NewBinding(
+			key.WithKeys("F"),
+			key.WithHelp("F", "follow latest build"),
+		),
+		PromptSubmit: key.NewBinding(
+			key.WithKeys("enter"),
+			key.WithHelp("enter", "run prompt"),
+		),
+		PromptCancel: key.NewBinding(
+			key.WithKeys("esc"),
+			key.WithHelp("esc", "cancel prompt"),
+		),
+	}
+}
+
+// keyHelp lists the keys of the program and the viewport for the help
+// shown in the footer, and in full with the Help key.
+type keyHelp struct {
+	app keyMap
+	log jlsviewport.KeyMap
+}
+
+func (h keyHelp) ShortHelp() []key.Binding {
+	return []key.Binding{h.app.Help, h.app.Search, h.app.Filter, h.log.NextMark, h.log.ToggleFold, h.log.Select, h.app.Quit}
+}
+
+func (h keyHelp) FullHelp() [][]key.Binding {
+	return [][]key.Binding{
+		{h.log.Up, h.log.Down, h.log.PageUp, h.log.PageDown, h.log.HalfPageUp, h.log.HalfPageDown, h.log.GotoTop, h.log.GotoBottom},
+		{h.app.Search, h.app.SearchBackward, h.log.NextMatch, h.log.PrevMatch, h.app.Filter, h.log.MoreContext, h.log.LessContext, h.log.NextMark, h.log.PrevMark},
+		{h.log.ToggleFold, h.log.CollapseAll, h.log.ExpandAll, h.log.ToggleWrap, h.log.ToggleColor, h.app.Timestamps, h.app.ToggleStages, h.app.FocusStages, h.app.ShowStage, h.app.PromptSubmit, h.app.PromptCancel},
+		{h.log.Select, h.log.SelectScreen, h.log.Copy, h.app.Command, h.app.Save, h.app.History, h.app.OpenBuild, h.app.FollowLatest, h.app.Back, h.app.Help, h.app.Quit, h.app.ForceQuit},
+	}
+}
+
+var helpStyle = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+
+// helpView shows the help for every key in the middle of the log.
+func (m model) helpView() string {
+	h := m.help
+	h.ShowAll = true
+	return lipgloss.Place(m.width, m.viewport.Height, lipgloss.Center, lipgloss.Center,
+		helpStyle.Render(h.View(keyHelp{app: m.keys, log: m.viewport.KeyMap})))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// Lines matching these are marked as errors and warnings unless other
+// patterns are given with --error-pattern and --warning-pattern.
+var (
+	defaultErrorPatterns = []string{
+		`\b(ERROR|FATAL|FAILED|FAILURE)\b`,
+		`(?i)\berror(\[\w+\])?:`,
+		`\w+(Exception|Error): `,
+		`\bCaused by: `,
+		`\bpanic: `,
+		`\bTraceback \(most recent call last\):`,
+		`npm ERR!`,
+	}
+	defaultWarningPatterns = []string{
+		`\bWARN(ING)?\b`,
+		`(?i)\bwarning:`,
+		`npm WARN`,
+	}
+)
+
+// The markers for errors and warnings, in order
+const (
+	errorMarker = iota
+	warningMarker
+)
+
+const (
+	errorLineStyle   = "\x1b[1;31m"
+	warningLineStyle = "\x1b[33m"
+)
+
+// problemMarkers returns the markers for lines that report errors and
+// warnings.
+func problemMarkers(errorPatterns, warningPatterns []string) ([]jlsviewport.Marker, error) {
+	errs, err := compilePatterns(errorPatterns)
+	if err != nil {
+		return nil, err
+	}
+	warnings, err := compilePatterns(warningPatterns)
+	if err != nil {
+		return nil, err
+	}
+	return []jlsviewport.Marker{
+		errorMarker:   {Patterns: errs, Style: errorLineStyle},
+		warningMarker: {Patterns: warnings, Style: warningLineStyle},
+	}, nil
+}
+
+func compilePatterns(patterns []string) ([]*regexp.Regexp, error) {
+	compiled := make([]*regexp.Regexp, len(patterns))
+	for i, p := range patterns {
+		var err error
+		compiled[i], err = regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", p, err)
+		}
+	}
+	return compiled, nil
+}
+
+// problemCounts describes the number of errors and warnings in the log.
+func problemCounts(counts []int) string {
+	if len(counts) == 0 || counts[errorMarker]+counts[warningMarker] == 0 {
+		return ""
+	}
+	return fmt.Sprintf(" %s, %s", plural(counts[errorMarker], "error"), plural(counts[warningMarker], "warning"))
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// promptKind is what the text typed at the prompt in the footer is for.
+type promptKind int
+
+const (
+	searchForwardPrompt promptKind = iota
+	searchBackwardPrompt
+	filterPrompt
+	commandPrompt
+)
+
+// promptSymbols are shown at the start of the prompt for each kind
+var promptSymbols = map[promptKind]string{
+	searchForwardPrompt:  "/",
+	searchBackwardPrompt: "?",
+	filterPrompt:         "&",
+	commandPrompt:        ":",
+}
+
+func newPrompt() textinput.Model {
+	input := textinput.New()
+	input.Prompt = ""
+	return input
+}
+
+// startPrompt shows the prompt in the footer, which receives the keys until
+// it's submitted with enter or cancelled with escape.
+func (m *model) startPrompt(kind promptKind) tea.Cmd {
+	m.prompt = kind
+	m.prompting = true
+	m.input.Prompt = promptSymbols[kind]
+	m.input.SetValue("")
+	return m.input.Focus()
+}
+
+// updatePrompt handles keys while the prompt is shown.
+func (m model) updatePrompt(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	switch {
+	case key.Matches(msg, m.keys.ForceQuit):
+		return m, tea.Quit
+	case key.Matches(msg, m.keys.PromptCancel):
+		m.prompting = false
+		m.input.Blur()
+		return m, nil
+	case key.Matches(msg, m.keys.PromptSubmit):
+		m.prompting = false
+		m.input.Blur()
+		switch m.prompt {
+		case searchForwardPrompt, searchBackwardPrompt:
+			m.search(m.input.Value(), m.prompt == searchBackwardPrompt)
+		case filterPrompt:
+			m.addFilter(m.input.Value())
+		case commandPrompt:
+			cmd := m.runCommand(m.input.Value())
+			return m, cmd
+		}
+		return m, nil
+	}
+	var cmd tea.Cmd
+	m.input, cmd = m.input.Update(msg)
+	return m, cmd
+}
+
+// search searches the log for a regular expression, which ignores case
+// unless it has upper case letters. An empty pattern repeats the last search.
+func (m *model) search(text string, backward bool) {
+	pattern := m.viewport.SearchPattern()
+	if text != "" {
+		var err error
+		pattern, err = compileSearch(text)
+		if err != nil {
+			m.notice = fmt.Sprintf("Invalid pattern: %s", err)
+			return
+		}
+	}
+	if pattern == nil {
+		return
+	}
+	if !m.viewport.Search(pattern, backward) {
+		m.notice = "Pattern not found"
+	}
+}
+
+// addFilter narrows the lines shown to the ones matching a regular
+// expression, or with a leading !, hides the ones matching it. Patterns
+// added one after the other combine. An empty pattern shows all lines again.
+func (m *model) addFilter(text string) {
+	if text == "" {
+		m.viewport.SetFilter(jlsviewport.Filter{})
+		return
+	}
+	exclude := strings.HasPrefix(text, "!")
+	pattern, err := compileSearch(strings.TrimPrefix(text, "!"))
+	if err != nil {
+		m.notice = fmt.Sprintf("Invalid pattern: %s", err)
+		return
+	}
+	filter := m.viewport.Filter()
+	if exclude {
+		filter.Exclude = append(slices.Clip(filter.Exclude), pattern)
+	} else {
+		filter.Include = append(slices.Clip(filter.Include), pattern)
+	}
+	m.viewport.SetFilter(filter)
+}
+
+// filterDescription lists the patterns of a filter as they were typed.
+func filterDescription(f jlsviewport.Filter) string {
+	var patterns []string
+	for _, p := range f.Include {
+		patterns = append(patterns, strings.TrimPrefix(p.String(), "(?i)"))
+	}
+	for _, p := range f.Exclude {
+		patterns = append(patterns, "!"+strings.TrimPrefix(p.String(), "(?i)"))
+	}
+	description := strings.Join(patterns, " & ")
+	if f.Context > 0 {
+		description += fmt.Sprintf(" +%d", f.Context)
+	}
+	return description
+}
+
+func compileSearch(text string) (*regexp.Regexp, error) {
+	for _, r := range text {
+		if unicode.IsUpper(r) {
+			return regexp.Compile(text)
+		}
+	}
+	return regexp.Compile("(?i)" + text)
+}
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+)
+
+// saveFormat is what :save writes to the file.
+type saveFormat int
+
+const (
+	// savePlain writes every line without escape sequences
+	savePlain saveFormat = iota
+	// saveRaw writes every line with its colors and hyperlinks
+	saveRaw
+	// saveVisible writes the lines shown, without escape sequences
+	saveVisible
+)
+
+var saveFormats = map[string]saveFormat{
+	"plain":   savePlain,
+	"raw":     saveRaw,
+	"visible": saveVisible,
+}
+
+// saveTimeFormat formats the timestamps saved in front of each line
+const saveTimeFormat = "2006-01-02 15:04:05.000"
+
+var unsafeFilename = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+type savedMsg struct {
+	path  string
+	lines int
+	err   error
+}
+
+// runCommand runs a command typed at the : prompt.
+func (m *model) runCommand(text string) tea.Cmd {
+	args := strings.Fields(text)
+	if len(args) == 0 {
+		return nil
+	}
+	switch args[0] {
+	case "save", "save!":
+		return m.save(args[1:], args[0] == "save!")
+	}
+	m.notice = fmt.Sprintf("Unknown command: %s", args[0])
+	return nil
+}
+
+// save writes the log of the current build, or of the stage it's filtered
+// to, to a file. The arguments are an optional format and file name. With
+// timestamps shown, each line starts with the time it was written. An
+// existing file is only replaced with force.
+func (m *model) save(args []string, force bool) tea.Cmd {
+	format := savePlain
+	if len(args) > 0 {
+		if f, ok := saveFormats[args[0]]; ok {
+			format = f
+			args = args[1:]
+		}
+	}
+	path := m.saveFilename()
+	if len(args) == 1 {
+		path = args[0]
+	} else if len(args) > 1 {
+		m.notice = "Usage: :save [plain|raw|visible] [file]"
+		return nil
+	}
+
+	lines := m.viewport.Lines()
+	shown := m.viewport.ShownLines()
+	if format != saveVisible {
+		shown = make([]int, len(lines))
+		for i := range lines {
+			shown[i] = i
+		}
+	}
+	// The content ends with a newline, which leaves an empty last line
+	if n := len(shown); n > 0 && shown[n-1] == len(lines)-1 && lines[shown[n-1]] == "" {
+		shown = shown[:n-1]
+	}
+	var times []time.Time
+	if m.timestampMode != timestampsOff && m.stageFilter == nil {
+		times = m.timestamps
+		if m.noTimestamper {
+			times = m.arrivals
+		}
+	}
+
+	var b strings.Builder
+	for _, i := range shown {
+		if times != nil {
+			if i < len(times) && !times[i].IsZero() {
+				b.WriteString(times[i].Local().Format(saveTimeFormat))
+			} else {
+				b.WriteString(strings.Repeat(" ", len(saveTimeFormat)))
+			}
+			b.WriteByte(' ')
+		}
+		if format == saveRaw {
+			b.WriteString(lines[i])
+		} else {
+			b.WriteString(ansi.Strip(lines[i]))
+		}
+		b.WriteByte('\n')
+	}
+	return writeLog(path, b.String(), len(shown), force)
+}
+
+// saveFilename returns the default file to save the log to, named after the
+// job and build, like "Folder-job-42.log".
+func (m model) saveFilename() string {
+	name := strings.TrimSuffix(m.jobName, fmt.Sprintf(" #%d", m.currentBuildNum))
+	name = strings.Trim(unsafeFilename.ReplaceAllString(name, "-"), "-")
+	if name == "" {
+		name = "build"
+	}
+	if m.stageFilter != nil {
+		name += "-" + strings.Trim(unsafeFilename.ReplaceAllString(m.stageFilter.stage.Name, "-"), "-")
+	}
+	return fmt.Sprintf("%s-%d.log", name, m.currentBuildNum)
+}
+
+func writeLog(path, text string, lines int, force bool) tea.Cmd {
+	return func() tea.Msg {
+		flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+		if !force {
+			flags |= os.O_EXCL
+		}
+		f, err := os.OpenFile(path, flags, 0o644)
+		if err != nil {
+			if errors.Is(err, fs.ErrExist) {
+				err = fmt.Errorf("%s exists, use :save! to replace it", path)
+			}
+			return savedMsg{path: path, err: err}
+		}
+		_, err = f.WriteString(text)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return savedMsg{path: path, lines: lines, err: err}
+	}
+}
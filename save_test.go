@@ -0,0 +1,139 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+const saveTestLog = "Started by user admin\n" +
+	"\x1b[31mERROR\x1b[0m: build failed\n" +
+	"see \x1b]8;;https://ci.example.com/job/x/1/\x1b\\build 1\x1b]8;;\x1b\\\n" +
+	"Finished: FAILURE\n"
+
+// saveTestModel returns a model showing saveTestLog, filtered to the lines
+// matching filter unless it's empty.
+func saveTestModel(filter string) model {
+	m := model{jobName: "Team/demo job #42", currentBuildNum: 42, viewport: jlsviewport.New(80, 10)}
+	m.viewport.SetContent(saveTestLog)
+	if filter != "" {
+		m.viewport.SetFilter(jlsviewport.Filter{Include: []*regexp.Regexp{regexp.MustCompile(filter)}})
+	}
+	return m
+}
+
+// runSave runs :save with args and returns the message it sends.
+func runSave(t *testing.T, m *model, args []string, force bool) savedMsg {
+	t.Helper()
+	cmd := m.save(args, force)
+	if cmd == nil {
+		t.Fatalf(":save %s did nothing, notice %q", strings.Join(args, " "), m.notice)
+	}
+	return cmd().(savedMsg)
+}
+
+func TestSave(t *testing.T) {
+	tests := []struct {
+		name   string
+		format string
+		filter string
+		want   string
+	}{
+		{
+			"plain", "plain", "",
+			"Started by user admin\nERROR: build failed\nsee build 1\nFinished: FAILURE\n",
+		},
+		{"raw", "raw", "", saveTestLog},
+		{"plain saves the lines filtered out", "plain", "ERROR|FAILURE", "Started by user admin\nERROR: build failed\nsee build 1\nFinished: FAILURE\n"},
+		{"raw saves the lines filtered out", "raw", "ERROR|FAILURE", saveTestLog},
+		{"visible", "visible", "ERROR|FAILURE", "ERROR: build failed\nFinished: FAILURE\n"},
+		{"visible without a filter", "visible", "", "Started by user admin\nERROR: build failed\nsee build 1\nFinished: FAILURE\n"},
+	}
+	for _, tt := range tests {
+		m := saveTestModel(tt.filter)
+		path := filepath.Join(t.TempDir(), "build.log")
+		msg := runSave(t, &m, []string{tt.format, path}, false)
+		if msg.err != nil {
+			t.Errorf("%s: saving failed: %s", tt.name, msg.err)
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != tt.want {
+			t.Errorf("%s: saved %q, want %q", tt.name, data, tt.want)
+		}
+		if want := strings.Count(tt.want, "\n"); msg.lines != want {
+			t.Errorf("%s: saved %d lines, want %d", tt.name, msg.lines, want)
+		}
+	}
+}
+
+func TestSaveTimestamps(t *testing.T) {
+	m := saveTestModel("")
+	m.timestampMode = timestampsClock
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	// The last line has no timestamp
+	m.timestamps = []time.Time{start, start.Add(1500 * time.Millisecond), start.Add(2 * time.Second)}
+	path := filepath.Join(t.TempDir(), "build.log")
+	if msg := runSave(t, &m, []string{path}, false); msg.err != nil {
+		t.Fatal(msg.err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "2024-05-01 09:00:00.000 Started by user admin\n" +
+		"2024-05-01 09:00:01.500 ERROR: build failed\n" +
+		"2024-05-01 09:00:02.000 see build 1\n" +
+		"                        Finished: FAILURE\n"
+	if string(data) != want {
+		t.Errorf("saved %q, want %q", data, want)
+	}
+}
+
+func TestSaveErrors(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "existing.log")
+	if err := os.WriteFile(existing, []byte("keep me\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	m := saveTestModel("")
+	msg := runSave(t, &m, []string{existing}, false)
+	if msg.err == nil || !strings.Contains(msg.err.Error(), "use :save! to replace it") {
+		t.Errorf("saving over a file: error %v, want a hint to use :save!", msg.err)
+	}
+	if data, _ := os.ReadFile(existing); string(data) != "keep me\n" {
+		t.Errorf("saving over a file without :save! changed it to %q", data)
+	}
+	if msg := runSave(t, &m, []string{existing}, true); msg.err != nil || msg.lines != 4 {
+		t.Errorf(":save! over a file = %d lines, %v, want 4 lines", msg.lines, msg.err)
+	}
+
+	missing := filepath.Join(dir, "missing", "build.log")
+	if msg := runSave(t, &m, []string{missing}, false); msg.err == nil {
+		t.Errorf("saving in a missing directory succeeded")
+	}
+
+	if cmd := m.save([]string{"plain", "a.log", "b.log"}, false); cmd != nil || !strings.HasPrefix(m.notice, "Usage:") {
+		t.Errorf(":save with two files: notice %q, want the usage", m.notice)
+	}
+}
+
+func TestSaveFilename(t *testing.T) {
+	m := saveTestModel("")
+	if got, want := m.saveFilename(), "Team-demo-job-42.log"; got != want {
+		t.Errorf("saveFilename() = %q, want %q", got, want)
+	}
+	m.jobName = "» #42"
+	if got, want := m.saveFilename(), "build-42.log"; got != want {
+		t.Errorf("saveFilename() without a job name = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+)
+
+// resolveJob works out the URL of the job to show and the server it's on,
+// either from a job alias in the config file or from the job's URL. Without
+// an explicit serverName, the server is the one whose URL the job's URL
+// starts with, if any.
+func resolveJob(cfg config, alias, jobUrl, serverName string) (string, serverConfig, error) {
+	if alias != "" {
+		job, ok := cfg.Jobs[alias]
+		if !ok {
+			return "", serverConfig{}, fmt.Errorf("unknown job %q, expected one of the [jobs] in the config file: %s",
+				alias, strings.Join(sortedKeys(cfg.Jobs), ", "))
+		}
+		if jobUrl != "" {
+			return "", serverConfig{}, fmt.Errorf("job %q and --url can't be used together", alias)
+		}
+		if serverName == "" {
+			serverName = job.Server
+		}
+		jobUrl = job.Job
+		if !strings.Contains(jobUrl, "://") {
+			server, ok := cfg.Servers[serverName]
+			if !ok {
+				return "", serverConfig{}, fmt.Errorf("job %q: unknown server %q", alias, serverName)
+			}
+			jobUrl = joinJobPath(server.URL, job.Job)
+		}
+	}
+	if jobUrl == "" {
+		return "", serverConfig{}, fmt.Errorf("jenkins URL not specified. Use --url option or a job from the config file")
+	}
+
+	if serverName == "" {
+		return jobUrl, serverFor(cfg.Servers, jobUrl), nil
+	}
+	server, ok := cfg.Servers[serverName]
+	if !ok {
+		return "", serverConfig{}, fmt.Errorf("unknown server %q, expected one of the [servers] in the config file: %s",
+			serverName, strings.Join(sortedKeys(cfg.Servers), ", "))
+	}
+	server.name = serverName
+	return jobUrl, server, nil
+}
+
+// serverFor returns the server with the longest URL jobUrl starts with.
+func serverFor(servers map[string]serverConfig, jobUrl string) serverConfig {
+	var found serverConfig
+	for name, s := range servers {
+		prefix := strings.TrimSuffix(s.URL, "/") + "/"
+		if s.URL != "" && strings.HasPrefix(jobUrl, prefix) && len(s.URL) > len(found.URL) {
+			found = s
+			found.name = name
+		}
+	}
+	return found
+}
+
+// tlsOptions returns the TLS options of the server, with the files given on
+// the command line in flags replacing the server's. Skipping verification
+// on either turns it off.
+func (s serverConfig) tlsOptions(flags jenkins.TLSOptions) jenkins.TLSOptions {
+	o := jenkins.TLSOptions{
+		CAFile:             s.CAFile,
+		CertFile:           s.CertFile,
+		KeyFile:            s.KeyFile,
+		InsecureSkipVerify: s.InsecureSkipVerify || flags.InsecureSkipVerify,
+	}
+	if flags.CAFile != "" {
+		o.CAFile = flags.CAFile
+	}
+	if flags.CertFile != "" {
+		o.CertFile, o.KeyFile = flags.CertFile, flags.KeyFile
+	}
+	return o
+}
+
+// requestHeaders returns the headers to send to the server, adding the
+// "Name: value" headers given on the command line in flags to the server's.
+// A header in flags replaces the server's header of the same name.
+func (s serverConfig) requestHeaders(flags []string) (http.Header, error) {
+	headers := make(http.Header)
+	for name, value := range s.Headers {
+		headers.Set(name, value)
+	}
+	set := make(map[string]bool)
+	for _, h := range flags {
+		name, value, ok := strings.Cut(h, ":")
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		if !ok || name == "" {
+			return nil, fmt.Errorf("invalid header %q: expected Name: value", h)
+		}
+		if !set[name] {
+			headers.Del(name)
+			set[name] = true
+		}
+		headers.Add(name, strings.TrimSpace(value))
+	}
+	return headers, nil
+}
+
+// joinJobPath returns the URL of the job at path, like "Projects/demo/main",
+// on the server at serverUrl.
+func joinJobPath(serverUrl, path string) string {
+	u := strings.TrimSuffix(serverUrl, "/")
+	for _, name := range strings.Split(strings.Trim(path, "/"), "/") {
+		u += "/job/" + url.PathEscape(name)
+	}
+	return u + "/"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
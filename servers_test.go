@@ -0,0 +1,91 @@
+package main
+
+import (
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+func TestResolveJob(t *testing.T) {
+	cfg := config{
+		Servers: map[string]serverConfig{
+			"ci":     {URL: "https://ci.example.com"},
+			"nested": {URL: "https://ci.example.com/team/"},
+			"other":  {URL: "https://other.example.com/"},
+		},
+		Jobs: map[string]jobConfig{
+			"demo":   {Server: "ci", Job: "Projects/demo/main"},
+			"spaces": {Server: "other", Job: "/My Folder/a job/"},
+			"full":   {Job: "https://other.example.com/job/full/"},
+			"nobody": {Server: "missing", Job: "demo"},
+		},
+	}
+	tests := []struct {
+		name       string
+		alias      string
+		jobUrl     string
+		serverName string
+		wantUrl    string
+		wantServer string
+		wantErr    bool
+	}{
+		{name: "alias", alias: "demo", wantUrl: "https://ci.example.com/job/Projects/job/demo/job/main/", wantServer: "ci"},
+		{name: "path escaped", alias: "spaces", wantUrl: "https://other.example.com/job/My%20Folder/job/a%20job/", wantServer: "other"},
+		{name: "alias with a URL", alias: "full", wantUrl: "https://other.example.com/job/full/", wantServer: "other"},
+		{name: "server given", alias: "full", serverName: "ci", wantUrl: "https://other.example.com/job/full/", wantServer: "ci"},
+		{name: "URL", jobUrl: "https://ci.example.com/job/x/", wantUrl: "https://ci.example.com/job/x/", wantServer: "ci"},
+		{name: "longest prefix", jobUrl: "https://ci.example.com/team/job/x/", wantUrl: "https://ci.example.com/team/job/x/", wantServer: "nested"},
+		{name: "prefix of a host", jobUrl: "https://ci.example.community/job/x/", wantUrl: "https://ci.example.community/job/x/", wantServer: ""},
+		{name: "URL on no server", jobUrl: "https://elsewhere/job/x/", wantUrl: "https://elsewhere/job/x/", wantServer: ""},
+		{name: "unknown alias", alias: "nope", wantErr: true},
+		{name: "alias and URL", alias: "demo", jobUrl: "https://ci.example.com/job/x/", wantErr: true},
+		{name: "alias on unknown server", alias: "nobody", wantErr: true},
+		{name: "unknown server", jobUrl: "https://ci.example.com/job/x/", serverName: "nope", wantErr: true},
+		{name: "nothing", wantErr: true},
+	}
+	for _, tt := range tests {
+		url, server, err := resolveJob(cfg, tt.alias, tt.jobUrl, tt.serverName)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("%s: resolveJob succeeded with %q on server %q, want an error", tt.name, url, server.name)
+			}
+			continue
+		}
+		if err != nil || url != tt.wantUrl || server.name != tt.wantServer {
+			t.Errorf("%s: resolveJob = %q on server %q, %v, want %q on server %q", tt.name, url, server.name, err, tt.wantUrl, tt.wantServer)
+		}
+	}
+}
+
+func TestRequestHeaders(t *testing.T) {
+	server := serverConfig{Headers: map[string]string{"X-Gateway-Key": "abc", "accept": "text/plain"}}
+	tests := []struct {
+		name    string
+		flags   []string
+		want    http.Header
+		wantErr bool
+	}{
+		{"server's", nil, http.Header{"X-Gateway-Key": {"abc"}, "Accept": {"text/plain"}}, false},
+		{
+			"added",
+			[]string{"X-Trace:  on ", "x-trace: twice"},
+			http.Header{"X-Gateway-Key": {"abc"}, "Accept": {"text/plain"}, "X-Trace": {"on", "twice"}},
+			false,
+		},
+		{
+			"replaced",
+			[]string{"Accept: text/html, text/plain"},
+			http.Header{"X-Gateway-Key": {"abc"}, "Accept": {"text/html, text/plain"}},
+			false,
+		},
+		{"empty value", []string{"X-Empty:"}, http.Header{"X-Gateway-Key": {"abc"}, "Accept": {"text/plain"}, "X-Empty": {""}}, false},
+		{"no colon", []string{"X-Broken"}, nil, true},
+		{"no name", []string{": value"}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := server.requestHeaders(tt.flags)
+		if (err != nil) != tt.wantErr || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: requestHeaders(%q) = %v, %v, want %v, error %v", tt.name, tt.flags, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
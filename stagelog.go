@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+	"github.com/jashort/jenkins-log-streamer/internal/console"
+)
+
+// stageFilter limits the log to the output of a single Pipeline stage.
+type stageFilter struct {
+	stage   jenkins.Stage
+	content string
+	// fetchedStatus is the stage status when the log was last fetched, so one
+	// last update is made after the stage finishes
+	fetchedStatus string
+	loaded        bool
+	// nodeLogs caches the complete logs of finished steps by node id
+	nodeLogs map[string]string
+}
+
+// needsUpdate reports whether the stage log may have changed since it was
+// fetched, given the latest stage status.
+func (f stageFilter) needsUpdate(status string) bool {
+	return !f.loaded || status == jenkins.StageInProgress || status != f.fetchedStatus
+}
+
+type stageLogMsg struct {
+	buildNum int
+	stageId  string
+	status   string
+	content  string
+	nodeLogs map[string]string
+	err      error
+}
+
+// fetchStageLog fetches the logs of every step in a stage, reusing cached
+// logs for steps that had already finished. With html, links and colors in
+// the logs are kept. It fails on its own, without backing off the log.
+func fetchStageLog(ctx context.Context, client *jenkins.Client, buildNum int, stage jenkins.Stage, cached map[string]string, html bool) tea.Cmd {
+	return func() tea.Msg {
+		build := strconv.Itoa(buildNum)
+		failed := func(err error) tea.Msg {
+			if errors.Is(err, context.Canceled) {
+				return nil
+			}
+			return stageLogMsg{buildNum: buildNum, stageId: stage.Id, err: err}
+		}
+		description, err := client.FetchStage(ctx, build, stage.Id)
+		if err != nil {
+			return failed(err)
+		}
+		nodeLogs := make(map[string]string, len(description.StageFlowNodes))
+		var content strings.Builder
+		for _, node := range description.StageFlowNodes {
+			text, complete := cached[node.Id]
+			if !complete {
+				text, complete, err = fetchNodeLog(ctx, client, build, node.Id, html)
+				if err != nil {
+					return failed(err)
+				}
+			}
+			if complete && node.Status != jenkins.StageInProgress {
+				nodeLogs[node.Id] = text
+			}
+			content.WriteString("[Pipeline] " + node.Name)
+			if node.ParameterDescription != "" {
+				content.WriteString(" (" + node.ParameterDescription + ")")
+			}
+			content.WriteString("\n")
+			content.WriteString(text)
+			if text != "" && !strings.HasSuffix(text, "\n") {
+				content.WriteString("\n")
+			}
+		}
+		return stageLogMsg{
+			buildNum: buildNum,
+			stageId:  stage.Id,
+			status:   stage.Status,
+			content:  content.String(),
+			nodeLogs: nodeLogs,
+		}
+	}
+}
+
+// fetchNodeLog fetches the log of a step. The workflow API cuts long logs
+// short, so those are fetched again from the step's own console. It reports
+// whether the log is complete, which it isn't if that failed too.
+func fetchNodeLog(ctx context.Context, client *jenkins.Client, build string, nodeId string, html bool) (string, bool, error) {
+	nodeLog, err := client.FetchNodeLog(ctx, build, nodeId)
+	if err != nil {
+		return "", false, err
+	}
+	text := nodeLog.PlainText()
+	if html {
+		converter := console.NewHtmlConverter(client.BuildUrl(build))
+		text = converter.Convert(nodeLog.Text) + converter.Flush()
+	}
+	if !nodeLog.HasMore {
+		return text, true, nil
+	}
+
+	full, err := client.FetchNodeConsole(ctx, build, nodeId, html)
+	if errors.Is(err, context.Canceled) {
+		return "", false, err
+	} else if err != nil {
+		if text != "" && !strings.HasSuffix(text, "\n") {
+			text += "\n"
+		}
+		return text + fmt.Sprintf("[Log cut short, loading the rest failed: %s]\n", err), false, nil
+	}
+	if html {
+		converter := console.NewHtmlConverter(client.BuildUrl(build))
+		text = converter.Convert(full.Body) + converter.Flush()
+	} else {
+		notes, colors := &console.NoteStripper{}, &console.ColorCarrier{}
+		text = colors.Carry(notes.Strip(full.Body)+notes.Flush()) + colors.Flush()
+	}
+	return text, !full.MoreData, nil
+}
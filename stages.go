@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+	"github.com/jashort/jenkins-log-streamer/internal/ansi"
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	// maxStagePanelWidth limits how much of the screen long stage names take
+	maxStagePanelWidth = 40
+	minViewportWidth   = 20
+)
+
+var (
+	stageCursorStyle   = lipgloss.NewStyle().Reverse(true)
+	stageSelectedStyle = lipgloss.NewStyle().Bold(true)
+
+	stagePanelStyle = lipgloss.NewStyle().
+			BorderStyle(lipgloss.NormalBorder()).
+			BorderLeft(true).
+			PaddingLeft(1)
+
+	stageIcons = map[string]string{
+		jenkins.StageSuccess:     lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Render("✔"),
+		jenkins.StageFailed:      lipgloss.NewStyle().Foreground(lipgloss.Color("9")).Render("✘"),
+		jenkins.StageUnstable:    lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render("▲"),
+		jenkins.StageAborted:     lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Render("⊘"),
+		jenkins.StageNotExecuted: lipgloss.NewStyle().Foreground(lipgloss.Color("8")).Render("○"),
+		jenkins.StagePausedInput: lipgloss.NewStyle().Foreground(lipgloss.Color("3")).Render("⏸"),
+	}
+)
+
+// stagePanel is the side panel listing the stages of a Pipeline build.
+type stagePanel struct {
+	hidden   bool
+	stages   []jenkins.Stage
+	spinner  spinner.Model
+	spinning bool
+	// focused moves the keyboard from the log to the panel, where the cursor
+	// picks a stage to filter the log to
+	focused  bool
+	cursor   int
+	selected string
+}
+
+func newStagePanel() stagePanel {
+	return stagePanel{spinner: spinner.New(spinner.WithSpinner(spinner.MiniDot))}
+}
+
+// visible reports whether the panel takes up space next to the viewport.
+func (p stagePanel) visible() bool {
+	return !p.hidden && len(p.stages) > 0
+}
+
+func (p stagePanel) running() bool {
+	for _, s := range p.stages {
+		if s.Status == jenkins.StageInProgress {
+			return true
+		}
+	}
+	return false
+}
+
+// setStages replaces the listed stages, starting the spinner when a stage is
+// running.
+type pipelineMsg struct {
+	buildNum int
+	run      *jenkins.PipelineRun
+	err      error
+}
+
+// fetchPipeline fetches the stages of a Pipeline build, separately from its
+// status so the status doesn't wait for the workflow API.
+func fetchPipeline(ctx context.Context, client *jenkins.Client, buildNum int) tea.Cmd {
+	return func() tea.Msg {
+		run, err := client.FetchPipeline(ctx, strconv.Itoa(buildNum))
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return pipelineMsg{buildNum: buildNum, run: run, err: err}
+	}
+}
+
+func (p *stagePanel) setStages(stages []jenkins.Stage) tea.Cmd {
+	p.stages = stages
+	p.cursor = min(p.cursor, max(0, len(stages)-1))
+	if len(stages) == 0 {
+		p.focused = false
+	}
+	if p.running() && !p.spinning {
+		p.spinning = true
+		return p.spinner.Tick
+	}
+	return nil
+}
+
+// update advances the spinner, letting it stop once nothing is running.
+func (p *stagePanel) update(msg spinner.TickMsg) tea.Cmd {
+	if !p.running() {
+		p.spinning = false
+		return nil
+	}
+	var cmd tea.Cmd
+	p.spinner, cmd = p.spinner.Update(msg)
+	return cmd
+}
+
+// moveCursor moves the cursor by n stages, staying within the list.
+func (p *stagePanel) moveCursor(n int) {
+	p.cursor = max(0, min(p.cursor+n, len(p.stages)-1))
+}
+
+// stage returns the stage under the cursor.
+func (p stagePanel) stage() (jenkins.Stage, bool) {
+	if p.cursor < 0 || p.cursor >= len(p.stages) {
+		return jenkins.Stage{}, false
+	}
+	return p.stages[p.cursor], true
+}
+
+// find returns the stage with the given id.
+func (p stagePanel) find(id string) (jenkins.Stage, bool) {
+	for _, s := range p.stages {
+		if s.Id == id {
+			return s, true
+		}
+	}
+	return jenkins.Stage{}, false
+}
+
+// succeeded reports whether the stage or parallel branch with the given
+// name finished successfully.
+func (p stagePanel) succeeded(name string) bool {
+	name = strings.TrimPrefix(name, "Branch: ")
+	for _, s := range p.stages {
+		if s.Name == name {
+			return s.Status == jenkins.StageSuccess
+		}
+	}
+	return false
+}
+
+// width returns the width of the panel including its border, leaving at
+// least minViewportWidth columns of totalWidth for the log.
+func (p stagePanel) width(totalWidth int) int {
+	if !p.visible() {
+		return 0
+	}
+	w := 0
+	for _, s := range p.stages {
+		w = max(w, lipgloss.Width(s.Name))
+	}
+	// icon, spaces, duration, border and padding
+	w += 2 + 1 + 8 + stagePanelStyle.GetHorizontalFrameSize()
+	return max(0, min(w, maxStagePanelWidth, totalWidth-minViewportWidth))
+}
+
+func (p stagePanel) View(width, height int) string {
+	contentWidth := max(0, width-stagePanelStyle.GetHorizontalFrameSize())
+	blockWidth := max(0, width-stagePanelStyle.GetHorizontalBorderSize())
+	lines := make([]string, 0, len(p.stages))
+	for i, s := range p.stages {
+		icon, ok := stageIcons[s.Status]
+		if s.Status == jenkins.StageInProgress {
+			icon = p.spinner.View()
+		} else if !ok {
+			icon = " "
+		}
+		duration := ""
+		if s.Status != jenkins.StageNotExecuted {
+			duration = formatDuration(s.Duration())
+		}
+		nameWidth := max(0, contentWidth-lipgloss.Width(icon)-lipgloss.Width(duration)-2)
+		name := runewidth.FillRight(runewidth.Truncate(s.Name, nameWidth, "…"), nameWidth)
+		switch {
+		case p.focused && i == p.cursor:
+			name = stageCursorStyle.Render(name)
+		case s.Id == p.selected:
+			name = stageSelectedStyle.Render(name)
+		}
+		lines = append(lines, fmt.Sprintf("%s %s %s", icon, name, duration))
+	}
+	return stagePanelStyle.Copy().
+		Width(blockWidth).
+		Height(height).
+		MaxHeight(height).
+		Render(strings.Join(lines, "\n"))
+}
+
+// formatDuration shortens a duration to at most two units, like 1h2m or 3m4s.
+func formatDuration(d time.Duration) string {
+	d = d.Round(time.Second)
+	switch {
+	case d >= time.Hour:
+		return fmt.Sprintf("%dh%dm", int(d.Hours()), int(d.Minutes())%60)
+	case d >= time.Minute:
+		return fmt.Sprintf("%dm%ds", int(d.Minutes()), int(d.Seconds())%60)
+	default:
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+}
+
+// joinColumns places right next to left, which is width cells wide. The
+// right column is positioned with an escape sequence rather than padding, so
+// it lines up even when the width of left is miscounted, as lipgloss does for
+// hyperlinks.
+func joinColumns(left string, width int, right string) string {
+	leftLines := strings.Split(left, "\n")
+	rightLines := strings.Split(right, "\n")
+	lines := make([]string, max(len(leftLines), len(rightLines)))
+	for i := range lines {
+		if i < len(leftLines) {
+			lines[i] = leftLines[i]
+		}
+		if i < len(rightLines) {
+			lines[i] += fmt.Sprintf("%s\x1b[%dG%s", ansi.Reset, width+1, rightLines[i])
+		}
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	jenkins "github.com/jashort/jenkins-log-streamer/internal"
+)
+
+// timestampMode selects what the timestamp gutter shows for each line.
+type timestampMode int
+
+const (
+	timestampsOff timestampMode = iota
+	// timestampsClock shows the time of day the line was written
+	timestampsClock
+	// timestampsElapsed shows the time since the build started
+	timestampsElapsed
+	// timestampsDelta shows the time since the previous line
+	timestampsDelta
+)
+
+// timestampModes names the modes, in the order the t key cycles through them
+var timestampModes = []string{"off", "clock", "elapsed", "delta"}
+
+func parseTimestampMode(s string) (timestampMode, error) {
+	for i, name := range timestampModes {
+		if s == name {
+			return timestampMode(i), nil
+		}
+	}
+	return timestampsOff, fmt.Errorf("invalid timestamp mode %q, must be one of %s", s, strings.Join(timestampModes, ", "))
+}
+
+func (t timestampMode) next() timestampMode {
+	return (t + 1) % timestampMode(len(timestampModes))
+}
+
+// timestampsWidth fits each mode's timestamps and a space after them
+const timestampsWidth = 9
+
+type timestampsMsg struct {
+	buildNum int
+	// start is the first line the timestamps are for
+	start int
+	times []time.Time
+	err   error
+}
+
+// fetchTimestamps fetches the Timestamper plugin's timestamps for the lines
+// of the log from start on.
+func fetchTimestamps(ctx context.Context, client *jenkins.Client, buildNum int, start int) tea.Cmd {
+	return func() tea.Msg {
+		times, err := client.FetchTimestamps(ctx, strconv.Itoa(buildNum), start)
+		if errors.Is(err, context.Canceled) {
+			return nil
+		}
+		return timestampsMsg{buildNum: buildNum, start: start, times: times, err: err}
+	}
+}
+
+// retryTimestampsMsg asks for the timestamps of a build to be fetched again
+// after fetching them failed.
+type retryTimestampsMsg struct{ buildNum int }
+
+// retryTimestamps sends a retryTimestampsMsg after delay.
+func retryTimestamps(buildNum int, delay time.Duration) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return retryTimestampsMsg{buildNum: buildNum}
+	})
+}
+
+// timestampGutter returns the gutter showing times, the time each line of
+// the log was written, for a build that started at buildStart. Lines with a
+// zero time have no timestamp.
+func timestampGutter(mode timestampMode, times []time.Time, buildStart time.Time) func(line int) string {
+	return func(line int) string {
+		if line >= len(times) || times[line].IsZero() {
+			return ""
+		}
+		t := times[line]
+		switch mode {
+		case timestampsClock:
+			return t.Local().Format(time.TimeOnly)
+		case timestampsElapsed:
+			return formatClockDuration(t.Sub(buildStart))
+		case timestampsDelta:
+			previous := buildStart
+			for i := line - 1; i >= 0; i-- {
+				if !times[i].IsZero() {
+					previous = times[i]
+					break
+				}
+			}
+			return fmt.Sprintf("%8s", formatDelta(t.Sub(previous)))
+		}
+		return ""
+	}
+}
+
+// formatClockDuration formats d as hours, minutes and seconds, like 01:02:03.
+func formatClockDuration(d time.Duration) string {
+	d = max(0, d).Truncate(time.Second)
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// formatDelta formats the time between two lines, with milliseconds while it
+// is short.
+func formatDelta(d time.Duration) string {
+	d = max(0, d)
+	if d < time.Minute {
+		return fmt.Sprintf("+%.3f", d.Seconds())
+	}
+	return "+" + formatDuration(d)
+}
+
+// addArrivals records now as the time the lines started by body arrived,
+// body being the next chunk of the log after content.
+func addArrivals(arrivals []time.Time, content string, body string, now time.Time) []time.Time {
+	started := strings.Count(body, "\n")
+	if !strings.HasSuffix(body, "\n") {
+		started++
+	}
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		// The first line of body continues the last line of content
+		started--
+	}
+	for i := 0; i < started; i++ {
+		arrivals = append(arrivals, now)
+	}
+	return arrivals
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/jashort/jenkins-log-streamer/internal/jlsviewport"
+)
+
+// lineStarts returns the offset of each line of log, leaving out the empty
+// line after a final newline.
+func lineStarts(log string) []int {
+	starts := []int{0}
+	for i, c := range log {
+		if c == '\n' && i+1 < len(log) {
+			starts = append(starts, i+1)
+		}
+	}
+	return starts
+}
+
+func TestAddArrivals(t *testing.T) {
+	logs := []string{
+		"first\nsecond\nthird\n",
+		"first\nsecond\nunfinished",
+		"\n\nafter blank lines\n",
+	}
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.UTC)
+	for _, log := range logs {
+		// Every split of the log into up to three chunks, each arriving a
+		// second after the one before
+		for i := 0; i <= len(log); i++ {
+			for j := i; j <= len(log); j++ {
+				ends := []int{i, j, len(log)}
+				var arrivals []time.Time
+				content := ""
+				for n, end := range ends {
+					body := log[len(content):end]
+					if body == "" {
+						continue
+					}
+					arrivals = addArrivals(arrivals, content, body, start.Add(time.Duration(n)*time.Second))
+					content += body
+				}
+
+				starts := lineStarts(log)
+				if len(arrivals) != len(starts) {
+					t.Errorf("%q split at %d and %d: %d arrivals for %d lines", log, i, j, len(arrivals), len(starts))
+					continue
+				}
+				for line, offset := range starts {
+					// A line arrives with the chunk it starts in
+					n := 0
+					for offset >= ends[n] {
+						n++
+					}
+					if want := start.Add(time.Duration(n) * time.Second); !arrivals[line].Equal(want) {
+						t.Errorf("%q split at %d and %d: line %d arrived at %s, want %s", log, i, j, line,
+							arrivals[line].Format(time.TimeOnly), want.Format(time.TimeOnly))
+					}
+				}
+			}
+		}
+	}
+}
+
+func TestArrivalsGutter(t *testing.T) {
+	start := time.Date(2024, 5, 1, 9, 0, 0, 0, time.Local)
+	var arrivals []time.Time
+	content := ""
+	for n, body := range []string{"one\ntw", "o\nthree is a long line\n", "four"} {
+		arrivals = addArrivals(arrivals, content, body, start.Add(time.Duration(n)*75*time.Second))
+		content += body
+	}
+
+	tests := []struct {
+		mode timestampMode
+		want []string
+	}{
+		{timestampsClock, []string{"09:00:00 one", "09:00:00 two", "09:01:15 three is", "         a long li", "         ne", "09:02:30 four"}},
+		{timestampsElapsed, []string{"00:00:00 one", "00:00:00 two", "00:01:15 three is", "         a long li", "         ne", "00:02:30 four"}},
+		{timestampsDelta, []string{"  +0.000 one", "  +0.000 two", "  +1m15s three is", "         a long li", "         ne", "  +1m15s four"}},
+	}
+	for _, tt := range tests {
+		m := jlsviewport.New(18, 10)
+		m.GutterStyle = lipgloss.NewStyle()
+		m.Gutter = timestampGutter(tt.mode, arrivals, start)
+		m.GutterWidth = timestampsWidth
+		m.SetContent(content)
+		got := strings.Split(m.View(), "\n")[:len(tt.want)]
+		for i := range got {
+			got[i] = strings.TrimRight(got[i], " ")
+		}
+		if fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: shows\n%q\nwant\n%q", timestampModes[tt.mode], got, tt.want)
+		}
+	}
+}